@@ -0,0 +1,192 @@
+package bot
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	adminCacheTTL = 5 * time.Minute
+	// adminRecheck is the age after which the cached admins are fetched again for a user who is not
+	// among them, so a user promoted since is not refused for adminCacheTTL
+	adminRecheck = 30 * time.Second
+)
+
+type adminEntry struct {
+	admins   map[int]bool
+	expireAt time.Time
+}
+
+type chatEntry struct {
+	chat     *tb.Chat
+	expireAt time.Time
+}
+
+// adminCache keeps the admin list and the info of groups and channels for adminCacheTTL,
+// so buttons and commands don't call AdminsOf and ChatByID on every update. Removed admins keep
+// their rights until the list expires.
+type adminCache struct {
+	mu      sync.Mutex
+	entries map[int64]adminEntry
+	chats   map[int64]chatEntry
+}
+
+var chatAdmins = &adminCache{entries: make(map[int64]adminEntry), chats: make(map[int64]chatEntry)}
+
+// IsAdmin reports whether userID is an admin of chat
+func (c *adminCache) IsAdmin(userID int, chat *tb.Chat) (bool, error) {
+	return c.isAdmin(userID, chat, true)
+}
+
+// Listed reports whether userID is among the cached admins of chat, the list is only fetched again
+// when it expired. Listings of many chats use it, IsAdmin would fetch again every chat the user is
+// not an admin of.
+func (c *adminCache) Listed(userID int, chat *tb.Chat) (bool, error) {
+	return c.isAdmin(userID, chat, false)
+}
+
+func (c *adminCache) isAdmin(userID int, chat *tb.Chat, recheck bool) (bool, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[chat.ID]
+	c.mu.Unlock()
+
+	now := time.Now()
+	stale := recheck && !entry.admins[userID] && now.After(entry.expireAt.Add(adminRecheck-adminCacheTTL))
+	if !ok || now.After(entry.expireAt) || stale {
+		members, err := B.AdminsOf(chat)
+		if err != nil {
+			return false, err
+		}
+		entry = adminEntry{admins: make(map[int]bool, len(members)), expireAt: time.Now().Add(adminCacheTTL)}
+		for _, member := range members {
+			entry.admins[member.User.ID] = true
+		}
+
+		c.mu.Lock()
+		c.entries[chat.ID] = entry
+		c.mu.Unlock()
+	}
+	return entry.admins[userID], nil
+}
+
+// Chat returns the chat of id, fetched again after adminCacheTTL
+func (c *adminCache) Chat(id int64) (*tb.Chat, error) {
+	c.mu.Lock()
+	entry, ok := c.chats[id]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expireAt) {
+		return entry.chat, nil
+	}
+
+	chat, err := B.ChatByID(fmt.Sprintf("%d", id))
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.chats[id] = chatEntry{chat: chat, expireAt: time.Now().Add(adminCacheTTL)}
+	c.mu.Unlock()
+	return chat, nil
+}
+
+// canManageChat reports whether user may manage the subscriptions of chat:
+// a private chat is managed by its user, groups and channels by their admins
+func canManageChat(user *tb.User, chat *tb.Chat) bool {
+	if user == nil {
+		return false
+	}
+	if chat.Type == tb.ChatPrivate {
+		return chat.ID == int64(user.ID)
+	}
+
+	isAdmin, err := chatAdmins.IsAdmin(user.ID, chat)
+	if err != nil {
+		zap.S().Warnf("get admins of chat %d failed, err:%+v", chat.ID, err)
+		return false
+	}
+	return isAdmin
+}
+
+// canManageOwner reports whether user may manage the subscriptions owned by ownerID
+func canManageOwner(user *tb.User, ownerID int64) bool {
+	if user != nil && ownerID == int64(user.ID) {
+		return true
+	}
+	chat, err := B.ChatByID(fmt.Sprintf("%d", ownerID))
+	if err != nil {
+		return false
+	}
+	return canManageChat(user, chat)
+}
+
+// stillManages reports whether userID, who set up a job of ownerID from another chat, still manages ownerID.
+// The jobs stop reporting to that chat once the user lost the admin rights.
+func stillManages(userID int64, ownerID int64) bool {
+	if userID == 0 {
+		return false
+	}
+	return canManageOwner(&tb.User{ID: int(userID)}, ownerID)
+}
+
+// canManageHere reports whether the sender of m may manage the chat m was sent in.
+// Channel posts have no sender, only channel admins can post there.
+func canManageHere(m *tb.Message) bool {
+	if m.Sender == nil {
+		return m.Chat.Type == tb.ChatChannel
+	}
+	return canManageChat(m.Sender, m.Chat)
+}
+
+// authTarget returns the chat a command acts on: the @channel of the command, the /use target
+// of a private chat or the current chat, after checking the sender manages it.
+// It replies with the reason and returns nil otherwise.
+func authTarget(m *tb.Message, lang string, mention string) *tb.Chat {
+	if mention == "" && m.Chat.Type == tb.ChatPrivate {
+		if target := chatOptions.Get(m.Chat.ID).Target; target != 0 {
+			return sessionTarget(m, lang, target)
+		}
+	}
+	if mention == "" {
+		if !canManageHere(m) {
+			// 无权限
+			return nil
+		}
+		return m.Chat
+	}
+
+	channelChat, err := B.ChatByID(mention)
+	if err != nil {
+		_, _ = B.Send(m.Chat, tr(lang, "channel_error"))
+		return nil
+	}
+	if !canManageChat(m.Sender, channelChat) {
+		_, _ = B.Send(m.Chat, tr(lang, "not_channel_admin"))
+		return nil
+	}
+	return channelChat
+}
+
+// authOwner checks the sender of m may manage the subscriptions owned by ownerID, it replies when not
+func authOwner(m *tb.Message, lang string, ownerID int64) bool {
+	if m.Sender == nil && ownerID == m.Chat.ID {
+		return canManageHere(m)
+	}
+	if !canManageOwner(m.Sender, ownerID) {
+		_, _ = B.Send(m.Chat, tr(lang, "permission_denied"))
+		return false
+	}
+	return true
+}
+
+// callbackAuth reports whether the button presser may manage the subscriptions of owner.
+// In groups only admins may press the buttons, whoever sent the command.
+func callbackAuth(c *tb.Callback, owner int64) bool {
+	if c.Message.Chat.Type != tb.ChatPrivate && !canManageChat(c.Sender, c.Message.Chat) {
+		return false
+	}
+	return owner == c.Message.Chat.ID || canManageOwner(c.Sender, owner)
+}
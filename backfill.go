@@ -0,0 +1,139 @@
+package bot
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/indes/flowerss-bot/config"
+	"github.com/indes/flowerss-bot/model"
+	"go.uber.org/zap"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// maxBackfill limits the number of items sent when subscribing
+const maxBackfill = 10
+
+// backfillArg returns the count of `--last N` or `--last=N` in args, def when there is none
+func backfillArg(args []string, def int) (int, bool) {
+	for i, arg := range args {
+		var value string
+		switch {
+		case arg == "--last" && i+1 < len(args):
+			value = args[i+1]
+		case strings.HasPrefix(arg, "--last="):
+			value = strings.TrimPrefix(arg, "--last=")
+		case arg == "--last":
+			return 0, false
+		default:
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > maxBackfill {
+			return 0, false
+		}
+		return n, true
+	}
+	return def, true
+}
+
+// hasBackfillArg reports whether args set the backfill count, several urls are imported without backfill
+func hasBackfillArg(args []string) bool {
+	for _, arg := range args {
+		if arg == "--last" || strings.HasPrefix(arg, "--last=") {
+			return true
+		}
+	}
+	return false
+}
+
+// subscribeWithBackfill runs subscribe, then sends the n latest items of link to owner when it was
+// not subscribed before
+func subscribeWithBackfill(owner int64, link string, n int, subscribe func()) {
+	if n <= 0 {
+		subscribe()
+		return
+	}
+	existing, _ := model.GetSubByUserIDAndURL(owner, link)
+	subscribe()
+	if existing != nil {
+		return
+	}
+	sub, err := model.GetSubByUserIDAndURL(owner, link)
+	if err != nil || sub == nil {
+		return
+	}
+	go backfillSub(sub, n)
+}
+
+// backfillSub sends the n latest items of the feed of sub like new items are pushed. Only the items the
+// source already stored are sent, the newer ones are pushed by the next update of the source.
+func backfillSub(sub *model.Subscribe, n int) {
+	source, err := model.GetSourceById(sub.SourceID)
+	if err != nil {
+		zap.S().Warnf("backfill sub %d failed, err:%+v", sub.ID, err)
+		return
+	}
+	// the feed gives the order of the items, the stored contents have no dates
+	feed, err := fetchFeed(source.Link)
+	if err != nil {
+		zap.S().Warnf("backfill sub %d failed, err:%+v", sub.ID, err)
+		return
+	}
+
+	stored := make(map[string]*model.Content, len(source.Content))
+	for i := range source.Content {
+		stored[source.Content[i].RawLink] = &source.Content[i]
+	}
+	var contents []*model.Content
+	for _, item := range newestItems(feed, len(feed.Items)) {
+		if content, ok := stored[item.Link]; ok && len(contents) < n {
+			contents = append(contents, content)
+		}
+	}
+
+	chat := &tb.Chat{ID: sub.UserID}
+	// oldest first, as they would have been pushed
+	for i := len(contents) - 1; i >= 0; i-- {
+		msg, err := newsMessage(source, sub, contents[i])
+		if err != nil {
+			zap.S().Warnf("render %s failed, err:%+v", contents[i].RawLink, err)
+			continue
+		}
+		_, err = B.Send(chat, msg, &tb.SendOptions{
+			DisableWebPagePreview: config.DisableWebPagePreview,
+			ParseMode:             config.MessageMode,
+			DisableNotification:   sub.EnableNotification != 1,
+		})
+		if err != nil {
+			zap.S().Warnf("backfill sub %d failed, err:%+v", sub.ID, err)
+			return
+		}
+	}
+}
+
+func backfillCmdCtr(m *tb.Message) {
+	lang := msgLang(m)
+	target := authTarget(m, lang, GetMentionFromMessage(m))
+	if target == nil {
+		return
+	}
+
+	args := commandArgs(m)
+	if len(args) == 0 {
+		_, _ = B.Send(m.Chat, tr(lang, "backfill_status", chatOptions.Get(target.ID).Backfill))
+		return
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 || n > maxBackfill {
+		_, _ = B.Send(m.Chat, tr(lang, "backfill_usage", maxBackfill))
+		return
+	}
+
+	if err := chatOptions.Update(target.ID, func(opt *ChatOption) { opt.Backfill = n }); err != nil {
+		zap.S().Errorf("set backfill of %d failed, err:%+v", target.ID, err)
+		_, _ = B.Send(m.Chat, tr(lang, "error"))
+		return
+	}
+	_, _ = B.Send(m.Chat, tr(lang, "backfill_set", n))
+}
@@ -0,0 +1,52 @@
+package bot
+
+import (
+	"testing"
+)
+
+func TestBackfillArg(t *testing.T) {
+	tests := []struct {
+		args   []string
+		want   int
+		wantOK bool
+	}{
+		{nil, 0, true},
+		{[]string{"https://example.com/rss"}, 0, true},
+		{[]string{"https://example.com/rss", "--last", "3"}, 3, true},
+		{[]string{"--last=5", "https://example.com/rss"}, 5, true},
+		{[]string{"--last=0"}, 0, true},
+		{[]string{"--last"}, 0, false},
+		{[]string{"--last", "many"}, 0, false},
+		{[]string{"--last=-1"}, 0, false},
+		{[]string{"--last", "11"}, 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := backfillArg(tt.args, 0)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("backfillArg(%q) = %d, %v, want %d, %v", tt.args, got, ok, tt.want, tt.wantOK)
+		}
+	}
+	if got, ok := backfillArg(nil, 2); got != 2 || !ok {
+		t.Errorf("backfillArg(nil, 2) = %d, %v, want the default", got, ok)
+	}
+}
+
+func TestHasBackfillArg(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{nil, false},
+		{[]string{"https://example.com/rss", "https://example.org/atom.xml"}, false},
+		{[]string{"https://example.com/rss", "https://example.org/atom.xml", "--last", "5"}, true},
+		{[]string{"--last=2", "https://example.com/rss", "https://example.org/atom.xml"}, true},
+		{[]string{"https://example.com/rss", "--lastly"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := hasBackfillArg(tt.args); got != tt.want {
+			t.Errorf("hasBackfillArg(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
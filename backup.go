@@ -0,0 +1,184 @@
+package bot
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// backupCheckInterval is how often backupLoop looks for due backups
+const backupCheckInterval = 10 * time.Minute
+
+var backupPeriods = map[string]time.Duration{
+	"daily":  24 * time.Hour,
+	"weekly": 7 * 24 * time.Hour,
+}
+
+// BackupSchedule is the periodic export of the subscriptions of a chat
+type BackupSchedule struct {
+	Period string `json:"period"`
+	Format string `json:"format"`
+	// SendTo receives the backups, the chat /backup was run in
+	SendTo int64  `json:"send_to"`
+	Lang   string `json:"lang"`
+	// SetBy is the user who ran /backup, checked again when SendTo is another chat than the owner
+	SetBy int64 `json:"set_by,omitempty"`
+
+	LastAt time.Time `json:"last_at"`
+	// Message is the last backup, the next one replaces its document
+	Message tb.StoredMessage `json:"message"`
+}
+
+func (b *BackupSchedule) due(now time.Time) bool {
+	return now.Sub(b.LastAt) >= backupPeriods[b.Period]
+}
+
+// backupLoop sends the due backups, the schedules are in the chat options so they survive restarts
+func backupLoop() {
+	ticker := time.NewTicker(backupCheckInterval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		for chatID, opt := range chatOptions.All() {
+			if opt.Backup != nil && opt.Backup.due(now) {
+				sendBackup(chatID, *opt.Backup)
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// sendBackup sends the export of the subscriptions of ownerID, replacing the previous backup
+func sendBackup(ownerID int64, schedule BackupSchedule) {
+	if schedule.SendTo != ownerID && !stillManages(schedule.SetBy, ownerID) {
+		zap.S().Warnf("backup of %d skipped, %d no longer manages it", ownerID, schedule.SetBy)
+		// checked again at the next period, not at every check
+		updateBackup(ownerID, nil)
+		return
+	}
+	list, err := subSourceList(ownerID, "")
+	if err != nil {
+		zap.S().Errorf("backup of %d failed, err:%+v", ownerID, err)
+		return
+	}
+
+	caption := tr(schedule.Lang, "backup_caption", len(list), time.Now().Format("2006-01-02 15:04"))
+	document := func() (*tb.Document, error) {
+		doc, err := exportDocumentOf(list, schedule.Format)
+		if err != nil {
+			return nil, err
+		}
+		doc.Caption = caption
+		return doc, nil
+	}
+
+	doc, err := document()
+	if err != nil {
+		zap.S().Errorf("backup of %d failed, err:%+v", ownerID, err)
+		return
+	}
+
+	var message *tb.Message
+	if schedule.Message.MessageID != "" {
+		message, err = B.EditMedia(schedule.Message, doc)
+		if err != nil {
+			// the old backup was deleted or is too old, send a new one
+			zap.S().Warnf("replace backup of %d failed, err:%+v", ownerID, err)
+			message = nil
+			// the upload consumed the file reader
+			doc, _ = document()
+		}
+	}
+	if message == nil {
+		if message, err = B.Send(&tb.Chat{ID: schedule.SendTo}, doc); err != nil {
+			zap.S().Errorf("send backup of %d to %d failed, err:%+v", ownerID, schedule.SendTo, err)
+		}
+	}
+
+	// retried at the next period when sending failed
+	updateBackup(ownerID, message)
+}
+
+// updateBackup starts the next period of the backup of ownerID, message is the backup sent if any
+func updateBackup(ownerID int64, message *tb.Message) {
+	err := chatOptions.Update(ownerID, func(opt *ChatOption) {
+		if opt.Backup == nil {
+			return
+		}
+		// a new schedule, readers may still hold the old one
+		backup := *opt.Backup
+		backup.LastAt = time.Now()
+		if message != nil {
+			backup.Message = tb.StoredMessage{MessageID: strconv.Itoa(message.ID), ChatID: message.Chat.ID}
+		}
+		opt.Backup = &backup
+	})
+	if err != nil {
+		zap.S().Errorf("save backup of %d failed, err:%+v", ownerID, err)
+	}
+}
+
+func backupCmdCtr(m *tb.Message) {
+	lang := msgLang(m)
+	target := authTarget(m, lang, GetMentionFromMessage(m))
+	if target == nil {
+		return
+	}
+
+	args := commandArgs(m)
+	if len(args) == 0 {
+		backup := chatOptions.Get(target.ID).Backup
+		if backup == nil {
+			_, _ = B.Send(m.Chat, tr(lang, "backup_off"))
+		} else {
+			_, _ = B.Send(m.Chat, tr(lang, "backup_status", backup.Period, backup.Format, backup.LastAt.Format("2006-01-02 15:04")))
+		}
+		return
+	}
+
+	period := strings.ToLower(args[0])
+	if period == "off" {
+		err := chatOptions.Update(target.ID, func(opt *ChatOption) { opt.Backup = nil })
+		if err != nil {
+			zap.S().Errorf("disable backup of %d failed, err:%+v", target.ID, err)
+			_, _ = B.Send(m.Chat, tr(lang, "error"))
+			return
+		}
+		_, _ = B.Send(m.Chat, tr(lang, "backup_disabled"))
+		return
+	}
+
+	format := "opml"
+	if len(args) > 1 {
+		format = strings.ToLower(args[1])
+	}
+	if _, ok := backupPeriods[period]; !ok || !isExportFormat(format) {
+		_, _ = B.Send(m.Chat, tr(lang, "backup_usage"))
+		return
+	}
+
+	schedule := BackupSchedule{Period: period, Format: format, SendTo: m.Chat.ID, Lang: lang}
+	if m.Sender != nil {
+		schedule.SetBy = int64(m.Sender.ID)
+	}
+	err := chatOptions.Update(target.ID, func(opt *ChatOption) {
+		if opt.Backup != nil && opt.Backup.SendTo == schedule.SendTo {
+			// keep replacing the same message
+			schedule.Message = opt.Backup.Message
+		}
+		opt.Backup = &schedule
+	})
+	if err != nil {
+		zap.S().Errorf("enable backup of %d failed, err:%+v", target.ID, err)
+		_, _ = B.Send(m.Chat, tr(lang, "error"))
+		return
+	}
+	_, _ = B.Send(m.Chat, tr(lang, "backup_enabled", period, format))
+
+	// the first backup is sent right away
+	go sendBackup(target.ID, schedule)
+}
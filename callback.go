@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	callbackStateKey = "callback_state"
+	callbackTTL      = 7 * 24 * time.Hour
+)
+
+// callbackPayload is what an inline button acts on. It is kept server side and the
+// button only carries a random token, so handlers never trust data coming from the client.
+type callbackPayload struct {
+	ChatID   int64  `json:"chat_id"`
+	Owner    int64  `json:"owner,omitempty"`
+	SubID    uint   `json:"sub_id,omitempty"`
+	SourceID uint   `json:"source_id,omitempty"`
+	Index    int    `json:"index"`
+	Kind     string `json:"kind,omitempty"`
+	Page     int    `json:"page,omitempty"`
+	Tag      string `json:"tag,omitempty"`
+	Arg      string `json:"arg,omitempty"`
+	// Backfill is the number of latest items sent when the button subscribes
+	Backfill int `json:"backfill,omitempty"`
+}
+
+type callbackEntry struct {
+	Payload  callbackPayload `json:"payload"`
+	ExpireAt time.Time       `json:"expire_at"`
+}
+
+type callbackStore struct {
+	once    sync.Once
+	mu      sync.Mutex
+	entries map[string]*callbackEntry
+	tokens  map[string]string // encoded payload -> token, so re-rendered buttons reuse their token
+	// dirty is set by Put, a page of buttons adds many tokens and flush saves them once
+	dirty bool
+}
+
+var callbacks = &callbackStore{}
+
+func (s *callbackStore) load() {
+	s.once.Do(func() {
+		s.entries = make(map[string]*callbackEntry)
+		s.tokens = make(map[string]string)
+		if err := persister.Load(callbackStateKey, &s.entries); err != nil {
+			zap.S().Errorf("load callback state failed, err:%+v", err)
+		}
+		for token, entry := range s.entries {
+			s.tokens[entry.Payload.key()] = token
+		}
+	})
+}
+
+func (p callbackPayload) key() string {
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+// Put stores payload and returns the token to put in the button data, it is saved by the next flush
+func (s *callbackStore) Put(payload callbackPayload) string {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	key := payload.key()
+	if token, ok := s.tokens[key]; ok {
+		s.entries[token].ExpireAt = now.Add(callbackTTL)
+		return token
+	}
+
+	for token, entry := range s.entries {
+		if now.After(entry.ExpireAt) {
+			delete(s.tokens, entry.Payload.key())
+			delete(s.entries, token)
+		}
+	}
+
+	token := newCallbackToken()
+	s.entries[token] = &callbackEntry{Payload: payload, ExpireAt: now.Add(callbackTTL)}
+	s.tokens[key] = token
+	s.dirty = true
+	return token
+}
+
+func (s *callbackStore) flush() {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return
+	}
+	if err := persister.Save(callbackStateKey, s.entries); err != nil {
+		zap.S().Errorf("save callback state failed, err:%+v", err)
+		return
+	}
+	s.dirty = false
+}
+
+// Get returns the payload of token when it exists, has not expired and was sent to chatID
+func (s *callbackStore) Get(token string, chatID int64) (callbackPayload, bool) {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[token]
+	if !ok || time.Now().After(entry.ExpireAt) || entry.Payload.ChatID != chatID {
+		return callbackPayload{}, false
+	}
+	return entry.Payload, true
+}
+
+func newCallbackToken() string {
+	b := make([]byte, 9)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand never fails on supported platforms
+		panic(fmt.Sprintf("read random token failed: %v", err))
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// newCallback stores payload for a button sent to chatID
+func newCallback(chatID int64, payload callbackPayload) string {
+	payload.ChatID = chatID
+	return callbacks.Put(payload)
+}
+
+// loadCallback returns the payload of the pressed button. Unknown or expired tokens, and
+// tokens replayed from another chat, are answered with an error and rejected.
+func loadCallback(c *tb.Callback) (callbackPayload, bool) {
+	var payload callbackPayload
+	ok := c.Message != nil
+	if ok {
+		payload, ok = callbacks.Get(c.Data, c.Message.Chat.ID)
+	}
+	if !ok {
+		_ = B.Respond(c, &tb.CallbackResponse{Text: tr(cbLang(c), "callback_expired"), ShowAlert: true})
+		return callbackPayload{}, false
+	}
+	return payload, true
+}
@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCallbackStore(t *testing.T) {
+	s := &callbackStore{}
+	payload := callbackPayload{ChatID: 10, Owner: 10, SubID: 3, Kind: "filter"}
+	token := s.Put(payload)
+
+	if got, ok := s.Get(token, 10); !ok || got != payload {
+		t.Errorf("Get() = %+v, %v, want %+v", got, ok, payload)
+	}
+	if _, ok := s.Get(token, 11); ok {
+		t.Error("Get() accepted the token in another chat")
+	}
+	if _, ok := s.Get("unknown", 10); ok {
+		t.Error("Get() accepted an unknown token")
+	}
+
+	other := s.Put(callbackPayload{ChatID: 11, Owner: 11})
+	if other == token {
+		t.Error("Put() reused the token of another payload")
+	}
+	if again := s.Put(payload); again != token {
+		t.Errorf("Put() of the same payload = %q, want %q", again, token)
+	}
+}
+
+func TestCallbackStoreExpiry(t *testing.T) {
+	s := &callbackStore{}
+	payload := callbackPayload{ChatID: 10, Kind: "watch", Arg: "golang"}
+	token := s.Put(payload)
+
+	ttl := time.Until(s.entries[token].ExpireAt)
+	if ttl < callbackTTL-time.Minute || ttl > callbackTTL {
+		t.Errorf("token expires in %v, want %v", ttl, callbackTTL)
+	}
+
+	s.entries[token].ExpireAt = time.Now().Add(-time.Second)
+	if _, ok := s.Get(token, 10); ok {
+		t.Error("Get() accepted an expired token")
+	}
+
+	// expired tokens are dropped when a new one is stored
+	s.Put(callbackPayload{ChatID: 10, Kind: "watch", Arg: "rust"})
+	if _, ok := s.entries[token]; ok {
+		t.Error("Put() kept the expired token")
+	}
+	if renewed := s.Put(payload); renewed == token {
+		t.Error("Put() reused an expired token")
+	}
+}
@@ -0,0 +1,122 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"sort"
+
+	"github.com/indes/flowerss-bot/model"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const channelViewCheck = "check"
+
+// subscribedChats returns the ids of the groups and channels with subscriptions, model has no way to list
+// the users so they are found through the subscribers of the sources
+func subscribedChats() []int64 {
+	seen := make(map[int64]bool)
+	var ids []int64
+	for _, source := range model.GetSources() {
+		for _, sub := range model.GetSubscriberBySource(source) {
+			// private chats have the positive id of their user
+			if sub.UserID < 0 && !seen[sub.UserID] {
+				seen[sub.UserID] = true
+				ids = append(ids, sub.UserID)
+			}
+		}
+	}
+	return ids
+}
+
+func channelsCmdCtr(m *tb.Message) {
+	lang := msgLang(m)
+	if m.Sender == nil {
+		return
+	}
+
+	// every subscribed chat is checked, the chats and their admins come from the cache
+	var chats []*tb.Chat
+	for _, id := range subscribedChats() {
+		chat, err := chatAdmins.Chat(id)
+		if err != nil {
+			continue
+		}
+		if isAdmin, err := chatAdmins.Listed(m.Sender.ID, chat); err == nil && isAdmin {
+			chats = append(chats, chat)
+		}
+	}
+	sort.Slice(chats, func(i, j int) bool {
+		if chatName(chats[i]) != chatName(chats[j]) {
+			return chatName(chats[i]) < chatName(chats[j])
+		}
+		return chats[i].ID < chats[j].ID
+	})
+
+	message := tr(lang, "channels_title")
+	var keys [][]tb.InlineButton
+	for _, chat := range chats {
+		id := chat.ID
+		subs, err := model.GetSubsByUserID(id)
+		if err != nil || len(subs) == 0 {
+			continue
+		}
+		errSources, _ := model.GetErrorSourcesByUserID(id)
+
+		message += "\n" + tr(lang, "channels_item", html.EscapeString(chatName(chat)), len(subs), len(errSources))
+		keys = append(keys, []tb.InlineButton{
+			tb.InlineButton{
+				Unique: "channel_view_btn",
+				Text:   chatName(chat),
+				Data:   newCallback(m.Chat.ID, callbackPayload{Owner: id, Kind: pageKindList}),
+			},
+			tb.InlineButton{
+				Unique: "channel_view_btn",
+				Text:   tr(lang, "btn_channel_set"),
+				Data:   newCallback(m.Chat.ID, callbackPayload{Owner: id, Kind: pageKindSet}),
+			},
+			tb.InlineButton{
+				Unique: "channel_view_btn",
+				Text:   tr(lang, "btn_channel_check", len(errSources)),
+				Data:   newCallback(m.Chat.ID, callbackPayload{Owner: id, Kind: channelViewCheck}),
+			},
+		})
+	}
+
+	if len(keys) == 0 {
+		_, _ = B.Send(m.Chat, tr(lang, "channels_empty"))
+		return
+	}
+	_, _ = B.Send(m.Chat, message, &tb.SendOptions{
+		ParseMode: tb.ModeHTML,
+	}, &tb.ReplyMarkup{
+		InlineKeyboard: keys,
+	})
+}
+
+func channelViewBtnCtr(c *tb.Callback) {
+	lang := cbLang(c)
+	payload, ok := loadCallback(c)
+	if !ok || !callbackAuth(c, payload.Owner) {
+		return
+	}
+
+	channel, err := B.ChatByID(fmt.Sprintf("%d", payload.Owner))
+	if err != nil {
+		_ = B.Respond(c, &tb.CallbackResponse{Text: tr(lang, "channel_error")})
+		return
+	}
+	_ = B.Respond(c)
+
+	switch payload.Kind {
+	case pageKindList:
+		sendPageTo(lang, c.Message.Chat, &pager{Kind: pageKindList, Owner: channel.ID}, channel)
+	case pageKindSet:
+		sendPageTo(lang, c.Message.Chat, &pager{Kind: pageKindSet, Owner: channel.ID}, nil)
+	case channelViewCheck:
+		_, _ = B.Send(c.Message.Chat, checkMessage(lang, channel.ID, channel), &tb.SendOptions{
+			DisableWebPagePreview: true,
+			ParseMode:             tb.ModeMarkdown,
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const chatOptionKey = "chat_options"
+
+// ChatOption holds the preferences of a chat (user, group or channel)
+type ChatOption struct {
+	Lang string `json:"lang,omitempty"`
+
+	// Target is the chat a private chat manages after /use, 0 for itself
+	Target int64 `json:"target,omitempty"`
+	// Backfill is the number of latest items sent when subscribing
+	Backfill int `json:"backfill,omitempty"`
+
+	// digest delivery, times are in TimeZone
+	TimeZone      string                  `json:"time_zone,omitempty"`
+	DigestHour    int                     `json:"digest_hour,omitempty"`
+	DigestMinute  int                     `json:"digest_minute,omitempty"`
+	DigestWeekday *time.Weekday           `json:"digest_weekday,omitempty"`
+	TagDelivery   map[string]deliveryMode `json:"tag_delivery,omitempty"`
+
+	Backup   *BackupSchedule `json:"backup,omitempty"`
+	OPMLSync *OPMLSync       `json:"opml_sync,omitempty"`
+
+	// Watches alert of the items of every subscription matching them
+	Watches []Watch `json:"watches,omitempty"`
+}
+
+type chatOptionStore struct {
+	once sync.Once
+	mu   sync.RWMutex
+	opts map[int64]*ChatOption
+	// dirty is set by UpdateLater, flush saves the options
+	dirty bool
+}
+
+var chatOptions = &chatOptionStore{}
+
+func (s *chatOptionStore) load() {
+	s.once.Do(func() {
+		s.opts = make(map[int64]*ChatOption)
+		if err := persister.Load(chatOptionKey, &s.opts); err != nil {
+			zap.S().Errorf("load chat options failed, err:%+v", err)
+		}
+	})
+}
+
+// Get returns a copy of the options of chatID
+func (s *chatOptionStore) Get(chatID int64) ChatOption {
+	s.load()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if opt, ok := s.opts[chatID]; ok {
+		return *opt
+	}
+	return ChatOption{}
+}
+
+// All returns a copy of the options of every chat
+func (s *chatOptionStore) All() map[int64]ChatOption {
+	s.load()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	all := make(map[int64]ChatOption, len(s.opts))
+	for chatID, opt := range s.opts {
+		all[chatID] = *opt
+	}
+	return all
+}
+
+// Update applies fn to the options of chatID and saves them
+func (s *chatOptionStore) Update(chatID int64, fn func(opt *ChatOption)) error {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apply(chatID, fn)
+	return s.save()
+}
+
+// UpdateLater applies fn to the options of chatID, they are saved by the next flush.
+// It is meant for the counters updated on every pushed item.
+func (s *chatOptionStore) UpdateLater(chatID int64, fn func(opt *ChatOption)) {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apply(chatID, fn)
+	s.dirty = true
+}
+
+// apply must be called with s.mu held
+func (s *chatOptionStore) apply(chatID int64, fn func(opt *ChatOption)) {
+	opt, ok := s.opts[chatID]
+	if !ok {
+		opt = &ChatOption{}
+		s.opts[chatID] = opt
+	}
+	fn(opt)
+}
+
+func (s *chatOptionStore) flush() {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return
+	}
+	if err := s.save(); err != nil {
+		zap.S().Errorf("save chat options failed, err:%+v", err)
+	}
+}
+
+// save must be called with s.mu held
+func (s *chatOptionStore) save() error {
+	if err := persister.Save(chatOptionKey, s.opts); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
@@ -1,13 +1,10 @@
 package bot
 
 import (
-	"bytes"
 	"fmt"
 	"go.uber.org/zap"
-	"html/template"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/indes/flowerss-bot/bot/fsm"
 	"github.com/indes/flowerss-bot/config"
@@ -16,508 +13,375 @@ import (
 	tb "gopkg.in/tucnak/telebot.v2"
 )
 
-var (
-	feedSettingTmpl = `
-Suscripcion<b>Configurar</b>
-[id] {{ .sub.ID }}
-[Titulo] {{ .source.Title }}
-[Link] {{.source.Link }}
-[Obtener actualizaciones] {{if ge .source.ErrorCount .Count }}se acab0 el tiempo{{else if lt .source.ErrorCount .Count }}Cerrar{{end}}
-[Frecuencia de rastreo] {{ .sub.Interval }}minuto
-[!] {{if eq .sub.EnableNotification 0}}Cerrar{{else if eq .sub.EnableNotification 1}}Enceneder{{end}}
-[Telegraph] {{if eq .sub.EnableTelegraph 0}}Cerrar{{else if eq .sub.EnableTelegraph 1}}Enceneder{{end}}
-[Tag] {{if .sub.Tag}}{{ .sub.Tag }}{{else}}No{{end}}
-`
-)
-
 func toggleCtrlButtons(c *tb.Callback, action string) {
+	lang := cbLang(c)
 
-	if (c.Message.Chat.Type == tb.ChatGroup || c.Message.Chat.Type == tb.ChatSuperGroup) &&
-		!userIsAdminOfGroup(c.Sender.ID, c.Message.Chat) {
-		// check admin
+	payload, ok := loadCallback(c)
+	if !ok || !callbackAuth(c, payload.Owner) {
 		return
 	}
 
-	data := strings.Split(c.Data, ":")
-	subscriberID, _ := strconv.Atoi(data[0])
-	if subscriberID != c.Sender.ID {
-		channelChat, err := B.ChatByID(fmt.Sprintf("%d", subscriberID))
-
-		if err != nil {
-			return
-		}
-
-		if !UserIsAdminChannel(c.Sender.ID, channelChat) {
-			return
-		}
-	}
-
-	msg := strings.Split(c.Message.Text, "\n")
-	subID, err := strconv.Atoi(strings.Split(msg[1], " ")[1])
-	if err != nil {
+	sub, err := model.GetSubscribeByID(int(payload.SubID))
+	if sub == nil || err != nil || sub.UserID != payload.Owner {
 		_ = B.Respond(c, &tb.CallbackResponse{
-			Text: "error",
+			Text: tr(lang, "error"),
 		})
 		return
 	}
-	sub, err := model.GetSubscribeByID(subID)
-	if sub == nil || err != nil {
+
+	source, err := model.GetSourceById(sub.SourceID)
+	if err != nil || source == nil {
 		_ = B.Respond(c, &tb.CallbackResponse{
-			Text: "error",
+			Text: tr(lang, "error"),
 		})
 		return
 	}
 
-	source, _ := model.GetSourceById(sub.SourceID)
-	t := template.New("setting template")
-	_, _ = t.Parse(feedSettingTmpl)
-
 	switch action {
 	case "toggleNotice":
 		err = sub.ToggleNotification()
 	case "toggleTelegraph":
 		err = sub.ToggleTelegraph()
 	case "toggleUpdate":
-		err = source.ToggleEnabled()
+		if source.ErrorCount >= config.ErrorThreshold {
+			// the feed stopped after too many errors, restart fetching it
+			err = source.ToggleEnabled()
+		} else {
+			err = subOptions.Update(func(opt *SubOption) { opt.Paused = !opt.Paused }, sub.ID)
+		}
+	case "toggleDelivery":
+		err = subOptions.Update(func(opt *SubOption) { opt.Delivery = opt.Delivery.next() }, sub.ID)
 	}
 
 	if err != nil {
 		_ = B.Respond(c, &tb.CallbackResponse{
-			Text: "error",
+			Text: tr(lang, "error"),
 		})
 		return
 	}
 
 	sub.Save()
 
-	text := new(bytes.Buffer)
-
-	_ = t.Execute(text, map[string]interface{}{"source": source, "sub": sub, "Count": config.ErrorThreshold})
 	_ = B.Respond(c, &tb.CallbackResponse{
-		Text: "Modificado con éxito",
+		Text: tr(lang, "modify_success"),
 	})
-	_, _ = B.Edit(c.Message, text.String(), &tb.SendOptions{
+	_, _ = B.Edit(c.Message, renderFeedSetting(lang, source, sub), &tb.SendOptions{
 		ParseMode: tb.ModeHTML,
 	}, &tb.ReplyMarkup{
-		InlineKeyboard: genFeedSetBtn(c, sub, source),
+		InlineKeyboard: genFeedSetBtn(lang, c.Message.Chat.ID, sub, source),
 	})
 }
 
 func startCmdCtr(m *tb.Message) {
 	user, _ := model.FindOrCreateUserByTelegramID(m.Chat.ID)
 	zap.S().Infof("/start user_id: %d telegram_id: %d", user.ID, user.TelegramID)
-	_, _ = B.Send(m.Chat, fmt.Sprintf("Hola, bienvenido a flowerss. "))
+	_, _ = B.Send(m.Chat, tr(msgLang(m), "start_welcome"))
 }
 
 func subCmdCtr(m *tb.Message) {
-
+	lang := msgLang(m)
 	url, mention := GetURLAndMentionFromMessage(m)
+	target := authTarget(m, lang, mention)
+	if target == nil {
+		return
+	}
 
-	if mention == "" {
+	if urls := subURLs(m.Payload); len(urls) > 1 {
+		if hasBackfillArg(commandArgs(m)) {
+			_, _ = B.Send(m.Chat, tr(lang, "backfill_single_url"))
+			return
+		}
+		subscribeURLs(m, lang, target.ID, urls)
+		return
+	}
+	backfill, ok := backfillArg(commandArgs(m), chatOptions.Get(target.ID).Backfill)
+	if !ok {
+		_, _ = B.Send(m.Chat, tr(lang, "backfill_usage", maxBackfill))
+		return
+	}
+
+	if target.ID == m.Chat.ID {
 		if url != "" {
-			registFeed(m.Chat, url)
+			subscribeURL(m, lang, target, url, backfill)
 		} else {
-			_, err := B.Send(m.Chat, "Responda a la URL de RSS ", &tb.ReplyMarkup{ForceReply: true})
+			_, err := B.Send(m.Chat, tr(lang, "sub_reply_url"), &tb.ReplyMarkup{ForceReply: true})
 			if err == nil {
-				UserState[m.Chat.ID] = fsm.Sub
+				chatStates.Set(m.Chat.ID, fsm.Sub, "")
 			}
 		}
 	} else {
 		if url != "" {
-			FeedForChannelRegister(m, url, mention)
+			subscribeURL(m, lang, target, url, backfill)
 		} else {
-			_, _ = B.Send(m.Chat, "Para suscribirse al canal, utilice el comando '/ sub @ChannelID URL'")
+			_, _ = B.Send(m.Chat, tr(lang, "sub_channel_usage"))
 		}
 	}
 
 }
 
-func exportCmdCtr(m *tb.Message) {
-
-	mention := GetMentionFromMessage(m)
-	var sourceList []model.Source
-	var err error
-	if mention == "" {
-
-		sourceList, err = model.GetSourcesByUserID(m.Chat.ID)
-		if err != nil {
-			zap.S().Warnf(err.Error())
-			_, _ = B.Send(m.Chat, fmt.Sprintf("Exportación fallida"))
-			return
-		}
-	} else {
-		channelChat, err := B.ChatByID(mention)
-
-		if err != nil {
-			_, _ = B.Send(m.Chat, "error")
-			return
+// subURLs returns the urls separated by spaces or new lines in text, without duplicates
+func subURLs(text string) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, field := range strings.Fields(text) {
+		if !CheckURL(field) {
+			continue
 		}
-
-		adminList, err := B.AdminsOf(channelChat)
-		if err != nil {
-			_, _ = B.Send(m.Chat, "error")
-			return
+		key, err := normalizeFeedURL(field)
+		if err != nil || seen[key] {
+			continue
 		}
+		seen[key] = true
+		urls = append(urls, field)
+	}
+	return urls
+}
 
-		senderIsAdmin := false
-		for _, admin := range adminList {
-			if m.Sender.ID == admin.User.ID {
-				senderIsAdmin = true
-			}
-		}
+// subscribeURLs subscribes owner to urls concurrently and sends one report, like an import.
+// The feeds of web pages are discovered like for a single url.
+func subscribeURLs(m *tb.Message, lang string, owner int64, urls []string) {
+	items := make([]importItem, 0, len(urls))
+	for _, url := range urls {
+		items = append(items, importItem{URL: url, Discover: true})
+	}
+	startImport(m, lang, owner, items)
+}
 
-		if !senderIsAdmin {
-			_, _ = B.Send(m.Chat, fmt.Sprintf("Los administradores que no son de canal no pueden realizar esta operación"))
-			return
-		}
+func exportCmdCtr(m *tb.Message) {
+	lang := msgLang(m)
+	target := authTarget(m, lang, GetMentionFromMessage(m))
+	if target == nil {
+		return
+	}
 
-		sourceList, err = model.GetSourcesByUserID(channelChat.ID)
-		if err != nil {
-			zap.S().Errorf(err.Error())
-			_, _ = B.Send(m.Chat, fmt.Sprintf("Exportación fallida"))
-			return
-		}
+	list, err := subSourceList(target.ID, "")
+	if err != nil {
+		zap.S().Errorf(err.Error())
+		_, _ = B.Send(m.Chat, tr(lang, "export_failed"))
+		return
 	}
 
-	if len(sourceList) == 0 {
-		_, _ = B.Send(m.Chat, fmt.Sprintf("La lista de suscripciones está vacía "))
+	if len(list) == 0 {
+		_, _ = B.Send(m.Chat, tr(lang, "sub_list_empty"))
 		return
 	}
 
-	opmlStr, err := ToOPML(sourceList)
+	format := "opml"
+	if args := commandArgs(m); len(args) > 0 {
+		format = strings.ToLower(args[0])
+	}
+	if !isExportFormat(format) {
+		_, _ = B.Send(m.Chat, tr(lang, "export_usage"))
+		return
+	}
 
+	exportFile, err := exportDocumentOf(list, format)
 	if err != nil {
-		_, _ = B.Send(m.Chat, fmt.Sprintf("Exportación fallida"))
+		_, _ = B.Send(m.Chat, tr(lang, "export_failed"))
 		return
 	}
-	opmlFile := &tb.Document{File: tb.FromReader(strings.NewReader(opmlStr))}
-	opmlFile.FileName = fmt.Sprintf("subscriptions_%d.opml", time.Now().Unix())
-	_, err = B.Send(m.Chat, opmlFile)
+	_, err = B.Send(m.Chat, exportFile)
 
 	if err != nil {
-		_, _ = B.Send(m.Chat, fmt.Sprintf("Exportación fallida"))
-		zap.S().Errorf("send opml file failed, err:%+v", err)
+		_, _ = B.Send(m.Chat, tr(lang, "export_failed"))
+		zap.S().Errorf("send %s file failed, err:%+v", format, err)
 	}
 
 }
 
 func listCmdCtr(m *tb.Message) {
-	mention := GetMentionFromMessage(m)
+	lang := msgLang(m)
+	target := authTarget(m, lang, GetMentionFromMessage(m))
+	if target == nil {
+		return
+	}
 
-	var rspMessage string
-	if mention != "" {
+	if target.ID != m.Chat.ID {
 		// channel feed list
-		channelChat, err := B.ChatByID(mention)
-		if err != nil {
-			_, _ = B.Send(m.Chat, "error")
-			return
-		}
-
-		if !checkPermitOfChat(int64(m.Sender.ID), channelChat) {
-			B.Send(m.Chat, fmt.Sprintf("Los administradores que no son de canal no pueden realizar esta operación"))
-			return
-		}
-
-		user, err := model.FindOrCreateUserByTelegramID(channelChat.ID)
-		if err != nil {
-			B.Send(m.Chat, fmt.Sprintf("Lista de errores internos @1"))
-			return
-		}
-
-		subSourceMap, err := user.GetSubSourceMap()
-		if err != nil {
-			B.Send(m.Chat, fmt.Sprintf("Lista de errores internos @2"))
-			return
-		}
-
-		sources, _ := model.GetSourcesByUserID(channelChat.ID)
-		rspMessage = fmt.Sprintf("Canal [%s] (https://t.me/%s) Lista de suscripción: \n", channelChat.Title, channelChat.Username)
-		if len(sources) == 0 {
-			rspMessage = fmt.Sprintf("La lista de suscripción del canal [%s] (https://t.me/%s) está vacía", channelChat.Title, channelChat.Username)
-		} else {
-			for sub, source := range subSourceMap {
-				rspMessage = rspMessage + fmt.Sprintf("[[%d]] [%s](%s)\n", sub.ID, source.Title, source.Link)
-			}
-		}
+		sendPage(m, pageKindList, target.ID, target)
 	} else {
 		// private chat or group
-		if m.Chat.Type != tb.ChatPrivate && !checkPermitOfChat(int64(m.Sender.ID), m.Chat) {
-			// 无权限
-			return
-		}
-
-		user, err := model.FindOrCreateUserByTelegramID(m.Chat.ID)
-		if err != nil {
-			B.Send(m.Chat, fmt.Sprintf("Lista de errores internos @1"))
-			return
-		}
+		sendPage(m, pageKindList, m.Chat.ID, nil)
+	}
+}
 
-		subSourceMap, err := user.GetSubSourceMap()
-		if err != nil {
-			B.Send(m.Chat, fmt.Sprintf("Lista de errores internos @2"))
-			return
-		}
+func checkCmdCtr(m *tb.Message) {
+	lang := msgLang(m)
+	target := authTarget(m, lang, GetMentionFromMessage(m))
+	if target == nil {
+		return
+	}
 
-		rspMessage = "Lista de suscripción actual：\n"
-		if len(subSourceMap) == 0 {
-			rspMessage = "La lista de suscripciones está vacía"
-		} else {
-			for sub, source := range subSourceMap {
-				rspMessage = rspMessage + fmt.Sprintf("[[%d]] [%s](%s)\n", sub.ID, source.Title, source.Link)
-			}
-		}
+	var channel *tb.Chat
+	if target.ID != m.Chat.ID {
+		channel = target
 	}
-	_, _ = B.Send(m.Chat, rspMessage, &tb.SendOptions{
+	_, _ = B.Send(m.Chat, checkMessage(lang, target.ID, channel), &tb.SendOptions{
 		DisableWebPagePreview: true,
 		ParseMode:             tb.ModeMarkdown,
 	})
 }
 
-func checkCmdCtr(m *tb.Message) {
-	mention := GetMentionFromMessage(m)
-	if mention != "" {
-		channelChat, err := B.ChatByID(mention)
-		if err != nil {
-			_, _ = B.Send(m.Chat, "error")
-			return
-		}
-		adminList, err := B.AdminsOf(channelChat)
-		if err != nil {
-			_, _ = B.Send(m.Chat, "error")
-			return
-		}
-
-		senderIsAdmin := false
-		for _, admin := range adminList {
-			if m.Sender.ID == admin.User.ID {
-				senderIsAdmin = true
-			}
-		}
-
-		if !senderIsAdmin {
-			_, _ = B.Send(m.Chat, fmt.Sprintf("Los administradores que no son de canal no pueden realizar esta operación"))
-			return
-		}
-
-		sources, _ := model.GetErrorSourcesByUserID(channelChat.ID)
-		message := fmt.Sprintf("Lista del canal [%s] (https://t.me/%s) de suscripciones caducadas: \n", channelChat.Title, channelChat.Username)
-		if len(sources) == 0 {
-			message = fmt.Sprintf("Canal [%s] (https://t.me/%s) Todas las suscripciones son normales", channelChat.Title, channelChat.Username)
-		} else {
-			for _, source := range sources {
-				message = message + fmt.Sprintf("[[%d]] [%s](%s)\n", source.ID, source.Title, source.Link)
-			}
-		}
-
-		_, _ = B.Send(m.Chat, message, &tb.SendOptions{
-			DisableWebPagePreview: true,
-			ParseMode:             tb.ModeMarkdown,
-		})
-
-	} else {
-		sources, _ := model.GetErrorSourcesByUserID(m.Chat.ID)
-		message := "Lista de suscripciones caducadas：\n"
-		if len(sources) == 0 {
-			message = "Todas las suscripciones son normales "
-		} else {
-			for _, source := range sources {
-				message = message + fmt.Sprintf("[[%d]] [%s](%s)\n", source.ID, source.Title, source.Link)
-			}
-		}
-		_, _ = B.Send(m.Chat, message, &tb.SendOptions{
-			DisableWebPagePreview: true,
-			ParseMode:             tb.ModeMarkdown,
-		})
+// checkMessage lists the failing sources of ownerID, channel is the owner chat when it is not the current one
+func checkMessage(lang string, ownerID int64, channel *tb.Chat) string {
+	sources, _ := model.GetErrorSourcesByUserID(ownerID)
+	var message string
+	switch {
+	case channel != nil && len(sources) == 0:
+		return tr(lang, "check_channel_ok", channel.Title, channel.Username)
+	case channel != nil:
+		message = tr(lang, "check_channel_title", channel.Title, channel.Username)
+	case len(sources) == 0:
+		return tr(lang, "check_ok")
+	default:
+		message = tr(lang, "check_title")
 	}
-
+	for _, source := range sources {
+		message = message + fmt.Sprintf("[[%d]] [%s](%s)\n", source.ID, source.Title, source.Link)
+	}
+	return message
 }
 
 func setCmdCtr(m *tb.Message) {
+	lang := msgLang(m)
+	target := authTarget(m, lang, GetMentionFromMessage(m))
+	if target == nil {
+		return
+	}
 
-	mention := GetMentionFromMessage(m)
-	var sources []model.Source
-	var ownerID int64
 	// 获取订阅列表
-	if mention == "" {
-		sources, _ = model.GetSourcesByUserID(m.Chat.ID)
-		ownerID = int64(m.Chat.ID)
-		if len(sources) <= 0 {
-			_, _ = B.Send(m.Chat, "Actualmente no hay feeds")
-			return
-		}
-
-	} else {
-
-		channelChat, err := B.ChatByID(mention)
-
-		if err != nil {
-			_, _ = B.Send(m.Chat, "Error al obtener la información del canal.")
-			return
-		}
-
-		if UserIsAdminChannel(m.Sender.ID, channelChat) {
-			sources, _ = model.GetSourcesByUserID(channelChat.ID)
-
-			if len(sources) <= 0 {
-				_, _ = B.Send(m.Chat, "Channel no tiene feeds.")
-				return
-			}
-			ownerID = channelChat.ID
-
+	sources, _ := model.GetSourcesByUserID(target.ID)
+	if len(sources) <= 0 {
+		if target.ID == m.Chat.ID {
+			_, _ = B.Send(m.Chat, tr(lang, "no_feeds"))
 		} else {
-			_, _ = B.Send(m.Chat, "Los administradores que no pertenecen al canal no pueden realizar esta operación.")
-			return
+			_, _ = B.Send(m.Chat, tr(lang, "set_channel_no_feeds"))
 		}
-
+		return
 	}
 
-	var replyButton []tb.ReplyButton
-	replyKeys := [][]tb.ReplyButton{}
-	setFeedItemBtns := [][]tb.InlineButton{}
-
 	// 配置按钮
-	for _, source := range sources {
-		// 添加按钮
-		text := fmt.Sprintf("%s %s", source.Title, source.Link)
-		replyButton = []tb.ReplyButton{
-			tb.ReplyButton{Text: text},
-		}
-		replyKeys = append(replyKeys, replyButton)
-
-		setFeedItemBtns = append(setFeedItemBtns, []tb.InlineButton{
-			tb.InlineButton{
-				Unique: "set_feed_item_btn",
-				Text:   fmt.Sprintf("[%d] %s", source.ID, source.Title),
-				Data:   fmt.Sprintf("%d:%d", ownerID, source.ID),
-			},
-		})
-	}
-
-	_, _ = B.Send(m.Chat, "Seleccione la fuente que desea configurar", &tb.ReplyMarkup{
-		InlineKeyboard: setFeedItemBtns,
-	})
+	sendPage(m, pageKindSet, target.ID, nil)
 }
 
 func setFeedItemBtnCtr(c *tb.Callback) {
+	lang := cbLang(c)
 
-	if (c.Message.Chat.Type == tb.ChatGroup || c.Message.Chat.Type == tb.ChatSuperGroup) &&
-		!userIsAdminOfGroup(c.Sender.ID, c.Message.Chat) {
-		return
-	}
-
-	data := strings.Split(c.Data, ":")
-	subscriberID, _ := strconv.Atoi(data[0])
-
+	payload, ok := loadCallback(c)
 	// 如果订阅者与按钮点击者id不一致，需要验证管理员权限
-
-	if subscriberID != c.Sender.ID {
-		channelChat, err := B.ChatByID(fmt.Sprintf("%d", subscriberID))
-
-		if err != nil {
-			return
-		}
-
-		if !UserIsAdminChannel(c.Sender.ID, channelChat) {
-			return
-		}
+	if !ok || !callbackAuth(c, payload.Owner) {
+		return
 	}
 
-	sourceID, _ := strconv.Atoi(data[1])
-
-	source, err := model.GetSourceById(uint(sourceID))
+	source, err := model.GetSourceById(payload.SourceID)
 
 	if err != nil {
-		_, _ = B.Edit(c.Message, "No se pudo encontrar el feed, código de error 01.")
+		_, _ = B.Edit(c.Message, tr(lang, "set_feed_not_found"))
 		return
 	}
 
-	sub, err := model.GetSubscribeByUserIDAndSourceID(int64(subscriberID), source.ID)
+	sub, err := model.GetSubscribeByUserIDAndSourceID(payload.Owner, source.ID)
 	if err != nil {
-		_, _ = B.Edit(c.Message, "El usuario no se ha suscrito al rss, código de error 02.")
+		_, _ = B.Edit(c.Message, tr(lang, "set_not_subscribed"))
 		return
 	}
 
-	t := template.New("setting template")
-	_, _ = t.Parse(feedSettingTmpl)
-	text := new(bytes.Buffer)
-	_ = t.Execute(text, map[string]interface{}{"source": source, "sub": sub, "Count": config.ErrorThreshold})
-
 	_, _ = B.Edit(
 		c.Message,
-		text.String(),
+		renderFeedSetting(lang, source, sub),
 		&tb.SendOptions{
 			ParseMode: tb.ModeHTML,
 		}, &tb.ReplyMarkup{
-			InlineKeyboard: genFeedSetBtn(c, sub, source),
+			InlineKeyboard: genFeedSetBtn(lang, c.Message.Chat.ID, sub, source),
 		},
 	)
 }
 
 func setSubTagBtnCtr(c *tb.Callback) {
-
+	lang := cbLang(c)
 	// 权限验证
-	if !feedSetAuth(c) {
+	payload, ok := loadCallback(c)
+	if !ok || !callbackAuth(c, payload.Owner) {
 		return
 	}
-	data := strings.Split(c.Data, ":")
-	ownID, _ := strconv.Atoi(data[0])
-	sourceID, _ := strconv.Atoi(data[1])
 
-	sub, err := model.GetSubscribeByUserIDAndSourceID(int64(ownID), uint(sourceID))
-	if err != nil {
+	sub, err := model.GetSubscribeByID(int(payload.SubID))
+	if err != nil || sub == nil || sub.UserID != payload.Owner {
 		_, _ = B.Send(
 			c.Message.Chat,
-			"error del sistema，Código 04",
+			tr(lang, "system_error", 4),
 		)
 		return
 	}
-	msg := fmt.Sprintf(
-		"Utilice el comando `/setfeedtag %d tags` para establecer etiquetas para esta suscripción. Las etiquetas son las etiquetas que se deben establecer, separadas por espacios. (Se pueden configurar hasta tres etiquetas) \n"+
-			"Por ejemplo: `/setfeedtag %d technology apple`",
-		sub.ID, sub.ID)
-
-	_ = B.Delete(c.Message)
-
-	_, _ = B.Send(
+	msgID, chatID := c.Message.MessageSig()
+	_, err = B.Send(
 		c.Message.Chat,
-		msg,
-		&tb.SendOptions{ParseMode: tb.ModeMarkdown},
+		tr(lang, "set_tag_reply", sub.ID),
+		&tb.ReplyMarkup{ForceReply: true},
 	)
+	if err != nil {
+		return
+	}
+	// remember the setting message so the reply can update it in place
+	chatStates.Set(c.Message.Chat.ID, fsm.SetSubTag, fmt.Sprintf("%d:%d:%s", sub.ID, chatID, msgID))
+	_ = B.Respond(c)
 }
 
-func genFeedSetBtn(c *tb.Callback, sub *model.Subscribe, source *model.Source) [][]tb.InlineButton {
+func genFeedSetBtn(lang string, chatID int64, sub *model.Subscribe, source *model.Source) [][]tb.InlineButton {
+	data := newCallback(chatID, callbackPayload{Owner: sub.UserID, SubID: sub.ID, SourceID: sub.SourceID})
+
 	setSubTagKey := tb.InlineButton{
 		Unique: "set_set_sub_tag_btn",
-		Text:   "Configuración de etiquetas",
-		Data:   c.Data,
+		Text:   tr(lang, "btn_set_tag"),
+		Data:   data,
 	}
 
 	toggleNoticeKey := tb.InlineButton{
 		Unique: "set_toggle_notice_btn",
-		Text:   "Activar notificación",
-		Data:   c.Data,
+		Text:   tr(lang, "btn_notice_on"),
+		Data:   data,
 	}
 	if sub.EnableNotification == 1 {
-		toggleNoticeKey.Text = "Cerrar notificación"
+		toggleNoticeKey.Text = tr(lang, "btn_notice_off")
 	}
 
 	toggleTelegraphKey := tb.InlineButton{
 		Unique: "set_toggle_telegraph_btn",
-		Text:   "Activar la transcodificación Telegraph",
-		Data:   c.Data,
+		Text:   tr(lang, "btn_telegraph_on"),
+		Data:   data,
 	}
 	if sub.EnableTelegraph == 1 {
-		toggleTelegraphKey.Text = "Desactivar la transcodificación Telegraph"
+		toggleTelegraphKey.Text = tr(lang, "btn_telegraph_off")
 	}
 
 	toggleEnabledKey := tb.InlineButton{
 		Unique: "set_toggle_update_btn",
-		Text:   "Pausar actualización",
-		Data:   c.Data,
+		Text:   tr(lang, "btn_update_pause"),
+		Data:   data,
 	}
 
 	if source.ErrorCount >= config.ErrorThreshold {
-		toggleEnabledKey.Text = "Reiniciar actualización"
+		toggleEnabledKey.Text = tr(lang, "btn_update_restart")
+	} else if subOptions.Get(sub.ID).Paused {
+		toggleEnabledKey.Text = tr(lang, "btn_update_resume")
+	}
+
+	setSubFilterKey := tb.InlineButton{
+		Unique: "set_sub_filter_btn",
+		Text:   tr(lang, "btn_set_filter"),
+		Data:   data,
+	}
+
+	toggleDeliveryKey := tb.InlineButton{
+		Unique: "set_toggle_delivery_btn",
+		Text:   tr(lang, "btn_delivery", subOptions.Get(sub.ID).Delivery.name(lang)),
+		Data:   data,
+	}
+
+	subLastKey := tb.InlineButton{
+		Unique: "sub_last_btn",
+		Text:   tr(lang, "btn_last"),
+		Data:   data,
 	}
 
 	feedSettingKeys := [][]tb.InlineButton{
@@ -529,6 +393,13 @@ func genFeedSetBtn(c *tb.Callback, sub *model.Subscribe, source *model.Source) [
 			toggleTelegraphKey,
 			setSubTagKey,
 		},
+		[]tb.InlineButton{
+			setSubFilterKey,
+			toggleDeliveryKey,
+		},
+		[]tb.InlineButton{
+			subLastKey,
+		},
 	}
 	return feedSettingKeys
 }
@@ -545,22 +416,30 @@ func setToggleUpdateBtnCtr(c *tb.Callback) {
 	toggleCtrlButtons(c, "toggleUpdate")
 }
 
-func unsubCmdCtr(m *tb.Message) {
+func setToggleDeliveryBtnCtr(c *tb.Callback) {
+	toggleCtrlButtons(c, "toggleDelivery")
+}
 
+func unsubCmdCtr(m *tb.Message) {
+	lang := msgLang(m)
 	url, mention := GetURLAndMentionFromMessage(m)
+	target := authTarget(m, lang, mention)
+	if target == nil {
+		return
+	}
 
-	if mention == "" {
+	if target.ID == m.Chat.ID {
 		if url != "" {
 			//Unsub by url
 			source, _ := model.GetSourceByUrl(url)
 			if source == nil {
-				_, _ = B.Send(m.Chat, "No suscrito a este canal RSS")
+				_, _ = B.Send(m.Chat, tr(lang, "unsub_not_subscribed"))
 			} else {
-				err := model.UnsubByUserIDAndSource(m.Chat.ID, source)
+				err := unsubSource(m.Chat.ID, source)
 				if err == nil {
 					_, _ = B.Send(
 						m.Chat,
-						fmt.Sprintf("[%s](%s) Dado de baja con éxito！", source.Title, source.Link),
+						tr(lang, "unsub_success", source.Title, source.Link),
 						&tb.SendOptions{
 							DisableWebPagePreview: true,
 							ParseMode:             tb.ModeMarkdown,
@@ -577,62 +456,19 @@ func unsubCmdCtr(m *tb.Message) {
 			subs, err := model.GetSubsByUserID(m.Chat.ID)
 
 			if err != nil {
-				errorCtr(m, "Error de bot, póngase en contacto con el administrador. Código de error 01")
+				errorCtr(m, tr(lang, "bot_error", 1))
 				return
 			}
 
 			if len(subs) > 0 {
-				unsubFeedItemBtns := [][]tb.InlineButton{}
-
-				for _, sub := range subs {
-
-					source, err := model.GetSourceById(sub.SourceID)
-					if err != nil {
-						errorCtr(m, "Error de bot, póngase en contacto con el administrador. Código de error 02")
-						return
-					}
-
-					unsubFeedItemBtns = append(unsubFeedItemBtns, []tb.InlineButton{
-						tb.InlineButton{
-							Unique: "unsub_feed_item_btn",
-							Text:   fmt.Sprintf("[%d] %s", sub.SourceID, source.Title),
-							Data:   fmt.Sprintf("%d:%d:%d", sub.UserID, sub.ID, source.ID),
-						},
-					})
-				}
-
-				_, _ = B.Send(m.Chat, "Seleccione la fuente de la que desea cancelar la suscripción", &tb.ReplyMarkup{
-					InlineKeyboard: unsubFeedItemBtns,
-				})
+				sendPage(m, pageKindUnsub, m.Chat.ID, nil)
 			} else {
-				_, _ = B.Send(m.Chat, "Actualmente no hay feeds")
+				_, _ = B.Send(m.Chat, tr(lang, "no_feeds"))
 			}
 		}
 	} else {
 		if url != "" {
-			channelChat, err := B.ChatByID(mention)
-			if err != nil {
-				_, _ = B.Send(m.Chat, "error")
-				return
-			}
-			adminList, err := B.AdminsOf(channelChat)
-			if err != nil {
-				_, _ = B.Send(m.Chat, "error")
-				return
-			}
-
-			senderIsAdmin := false
-			for _, admin := range adminList {
-				if m.Sender.ID == admin.User.ID {
-					senderIsAdmin = true
-				}
-			}
-
-			if !senderIsAdmin {
-				_, _ = B.Send(m.Chat, fmt.Sprintf("Los administradores que no son de canal no pueden realizar esta operación"))
-				return
-			}
-
+			channelChat := target
 			source, _ := model.GetSourceByUrl(url)
 			sub, err := model.GetSubByUserIDAndURL(channelChat.ID, url)
 
@@ -640,7 +476,7 @@ func unsubCmdCtr(m *tb.Message) {
 				if err.Error() == "record not found" {
 					_, _ = B.Send(
 						m.Chat,
-						fmt.Sprintf("Canal [%s](https://t.me/%s) No suscrito a este canal RSS", channelChat.Title, channelChat.Username),
+						tr(lang, "unsub_channel_not_subscribed", channelChat.Title, channelChat.Username),
 						&tb.SendOptions{
 							DisableWebPagePreview: true,
 							ParseMode:             tb.ModeMarkdown,
@@ -648,17 +484,17 @@ func unsubCmdCtr(m *tb.Message) {
 					)
 
 				} else {
-					_, _ = B.Send(m.Chat, "No se pudo cancelar la suscripción")
+					_, _ = B.Send(m.Chat, tr(lang, "unsub_failed"))
 				}
 				return
 
 			}
 
-			err = sub.Unsub()
+			err = unsubSub(sub)
 			if err == nil {
 				_, _ = B.Send(
 					m.Chat,
-					fmt.Sprintf("Canal [%s](https://t.me/%s) Darse de baja [%s](%s) éxito", channelChat.Title, channelChat.Username, source.Title, source.Link),
+					tr(lang, "unsub_channel_success", channelChat.Title, channelChat.Username, source.Title, source.Link),
 					&tb.SendOptions{
 						DisableWebPagePreview: true,
 						ParseMode:             tb.ModeMarkdown,
@@ -671,29 +507,36 @@ func unsubCmdCtr(m *tb.Message) {
 			return
 
 		}
-		_, _ = B.Send(m.Chat, "Para cancelar la suscripción del canal, utilice' /unsub @ChannelID URL ' mando")
+
+		//Unsub by button
+		subs, err := model.GetSubsByUserID(target.ID)
+		if err != nil {
+			errorCtr(m, tr(lang, "bot_error", 1))
+			return
+		}
+		if len(subs) > 0 {
+			sendPage(m, pageKindUnsub, target.ID, nil)
+		} else {
+			_, _ = B.Send(m.Chat, tr(lang, "set_channel_no_feeds"))
+		}
 	}
 
 }
 
 func unsubFeedItemBtnCtr(c *tb.Callback) {
+	lang := cbLang(c)
 
-	if (c.Message.Chat.Type == tb.ChatGroup || c.Message.Chat.Type == tb.ChatSuperGroup) &&
-		!userIsAdminOfGroup(c.Sender.ID, c.Message.Chat) {
+	payload, ok := loadCallback(c)
+	if !ok || !callbackAuth(c, payload.Owner) {
 		// check admin
 		return
 	}
 
-	data := strings.Split(c.Data, ":")
-	if len(data) == 3 {
-		userID, _ := strconv.Atoi(data[0])
-		subID, _ := strconv.Atoi(data[1])
-		sourceID, _ := strconv.Atoi(data[2])
-		source, _ := model.GetSourceById(uint(sourceID))
-
-		rtnMsg := fmt.Sprintf("[%d] <a href=\"%s\">%s</a> Darse de baja con éxito", sourceID, source.Link, source.Title)
+	source, err := model.GetSourceById(payload.SourceID)
+	if err == nil {
+		rtnMsg := tr(lang, "unsub_item_success", payload.SourceID, source.Link, source.Title)
 
-		err := model.UnsubByUserIDAndSubID(int64(userID), uint(subID))
+		err := unsubSubID(payload.Owner, payload.SubID)
 
 		if err == nil {
 			_, _ = B.Edit(
@@ -706,29 +549,35 @@ func unsubFeedItemBtnCtr(c *tb.Callback) {
 			return
 		}
 	}
-	_, _ = B.Edit(c.Message, "Error de cancelación de suscripción！")
+	_, _ = B.Edit(c.Message, tr(lang, "unsub_item_failed"))
 }
 
 func unsubAllCmdCtr(m *tb.Message) {
-	mention := GetMentionFromMessage(m)
+	lang := msgLang(m)
+	target := authTarget(m, lang, GetMentionFromMessage(m))
+	if target == nil {
+		return
+	}
+
 	confirmKeys := [][]tb.InlineButton{}
 	confirmKeys = append(confirmKeys, []tb.InlineButton{
 		tb.InlineButton{
 			Unique: "unsub_all_confirm_btn",
-			Text:   "confirmar",
+			Text:   tr(lang, "btn_confirm"),
+			Data:   newCallback(m.Chat.ID, callbackPayload{Owner: target.ID, Kind: "unsuball"}),
 		},
 		tb.InlineButton{
 			Unique: "unsub_all_cancel_btn",
-			Text:   "cancelar",
+			Text:   tr(lang, "btn_cancel"),
 		},
 	})
 
 	var msg string
 
-	if mention == "" {
-		msg = "Desea cancelar todas las suscripciones del usuario actual？"
+	if target.ID == m.Chat.ID {
+		msg = tr(lang, "unsuball_confirm")
 	} else {
-		msg = fmt.Sprintf("%s Desea darse de baja Channel Todas las suscripciones？", mention)
+		msg = tr(lang, "unsuball_channel_confirm", chatName(target))
 	}
 
 	_, _ = B.Send(
@@ -743,40 +592,23 @@ func unsubAllCmdCtr(m *tb.Message) {
 }
 
 func unsubAllCancelBtnCtr(c *tb.Callback) {
-	_, _ = B.Edit(c.Message, "Operación cancelada")
+	_, _ = B.Edit(c.Message, tr(cbLang(c), "operation_cancelled"))
 }
 
 func unsubAllConfirmBtnCtr(c *tb.Callback) {
-	mention := GetMentionFromMessage(c.Message)
-	var msg string
-	if mention == "" {
-		success, fail, err := model.UnsubAllByUserID(int64(c.Sender.ID))
-		if err != nil {
-			msg = "No se pudo cancelar la suscripción"
-		} else {
-			msg = fmt.Sprintf("Darse de baja con éxito：%d\nNo se pudo cancelar la suscripción：%d", success, fail)
-		}
+	lang := cbLang(c)
+	payload, ok := loadCallback(c)
+	if !ok || payload.Kind != "unsuball" {
+		return
+	}
 
+	var msg string
+	if !callbackAuth(c, payload.Owner) {
+		msg = tr(lang, "not_channel_admin")
+	} else if success, fail, err := unsubAll(payload.Owner); err != nil {
+		msg = tr(lang, "unsub_failed")
 	} else {
-		channelChat, err := B.ChatByID(mention)
-
-		if err != nil {
-			_, _ = B.Edit(c.Message, "error")
-			return
-		}
-
-		if UserIsAdminChannel(c.Sender.ID, channelChat) {
-			success, fail, err := model.UnsubAllByUserID(channelChat.ID)
-			if err != nil {
-				msg = "No se pudo cancelar la suscripción"
-
-			} else {
-				msg = fmt.Sprintf("Darse de baja con éxito：%d\nNo se pudo cancelar la suscripción：%d", success, fail)
-			}
-
-		} else {
-			msg = "Los administradores que no son de canal no pueden realizar esta operación"
-		}
+		msg = tr(lang, "unsuball_result", success, fail)
 	}
 
 	_, _ = B.Edit(c.Message, msg)
@@ -791,25 +623,7 @@ func pingCmdCtr(m *tb.Message) {
 }
 
 func helpCmdCtr(m *tb.Message) {
-	message := `
-Comandos：
-/sub Alimentar
-/unsub  darse de baja
-/list Ver feeds actuales
-/set Configurar suscripción
-/check Verificar suscripción actual
-/setfeedtag Establecer etiqueta de suscripción
-/setinterval Establecer la frecuencia de actualización de la suscripción
-/activeall Activar todas las suscripciones
-/pauseall Suspender todas las suscripciones
-/help ayuda
-/import Importar archivos OPML
-/export Exportar archivos OPML
-/unsuball Cancelar todas las suscripciones
-Para obtener información detallada sobre el uso, consulte：https://github.com/indes/flowerss-bot
-`
-
-	_, _ = B.Send(m.Chat, message)
+	_, _ = B.Send(m.Chat, tr(msgLang(m), "help"))
 }
 
 func versionCmdCtr(m *tb.Message) {
@@ -817,80 +631,59 @@ func versionCmdCtr(m *tb.Message) {
 }
 
 func importCmdCtr(m *tb.Message) {
-	message := `Envíe el archivo OPML directamente，
-Si necesita importar OPML para el canal, incluya la identificación del canal al enviar el archivo, como @telegram
-`
-	_, _ = B.Send(m.Chat, message)
+	_, _ = B.Send(m.Chat, tr(msgLang(m), "import_help"))
 }
 
 func setFeedTagCmdCtr(m *tb.Message) {
-	mention := GetMentionFromMessage(m)
-	args := strings.Split(m.Payload, " ")
+	lang := msgLang(m)
+	args := commandArgs(m)
 
 	if len(args) < 1 {
-		B.Send(m.Chat, "/setfeedtag [sub id] [tag1] [tag2] Establecer etiquetas de suscripción (configurar hasta tres etiquetas, separadas por espacios）")
+		B.Send(m.Chat, tr(lang, "setfeedtag_usage"))
 		return
 	}
 
-	var subID int
-	var err error
-	if mention == "" {
-		// 截短参数
-		if len(args) > 4 {
-			args = args[:4]
-		}
-		subID, err = strconv.Atoi(args[0])
-		if err != nil {
-			B.Send(m.Chat, "Ingrese el ID de suscripción correcto!")
-			return
-		}
-	} else {
-		if len(args) > 5 {
-			args = args[:5]
-		}
-		subID, err = strconv.Atoi(args[1])
-		if err != nil {
-			B.Send(m.Chat, "Ingrese el ID de suscripción correcto!")
-			return
-		}
+	// 截短参数
+	if len(args) > 4 {
+		args = args[:4]
+	}
+	subID, err := strconv.Atoi(args[0])
+	if err != nil {
+		B.Send(m.Chat, tr(lang, "invalid_sub_id"))
+		return
 	}
 
 	sub, err := model.GetSubscribeByID(subID)
 	if err != nil || sub == nil {
-		B.Send(m.Chat, "Ingrese el ID de suscripción correcto")
+		B.Send(m.Chat, tr(lang, "invalid_sub_id"))
 		return
 	}
 
-	if !checkPermit(int64(m.Sender.ID), sub.UserID) {
-		B.Send(m.Chat, "¡Permiso denegado!")
+	if !authOwner(m, lang, sub.UserID) {
 		return
 	}
 
-	if mention == "" {
-		err = sub.SetTag(args[1:])
-	} else {
-		err = sub.SetTag(args[2:])
-	}
+	err = sub.SetTag(args[1:])
 
 	if err != nil {
-		B.Send(m.Chat, "No se pudo establecer la etiqueta de suscripción!")
+		B.Send(m.Chat, tr(lang, "settag_failed"))
 		return
 	}
-	B.Send(m.Chat, "La etiqueta de suscripción se estableció correctamente!")
+	B.Send(m.Chat, tr(lang, "settag_success"))
 }
 
 func setIntervalCmdCtr(m *tb.Message) {
-
+	lang := msgLang(m)
 	args := strings.Split(m.Payload, " ")
 
 	if len(args) < 1 {
-		_, _ = B.Send(m.Chat, "/setinterval [interval] [sub id] Establecer la frecuencia de actualización de la suscripción (se pueden establecer múltiples sub id, separados por espacios）")
+		_, _ = B.Send(m.Chat, tr(lang, "setinterval_usage"))
 		return
 	}
 
 	interval, err := strconv.Atoi(args[0])
 	if interval <= 0 || err != nil {
-		_, _ = B.Send(m.Chat, "Ingrese la frecuencia de rastreo correcta")
+		_, _ = B.Send(m.Chat, tr(lang, "invalid_interval"))
 		return
 	}
 
@@ -898,58 +691,42 @@ func setIntervalCmdCtr(m *tb.Message) {
 
 		subID, err := strconv.Atoi(id)
 		if err != nil {
-			_, _ = B.Send(m.Chat, "Ingrese el ID de suscripción correcto!")
+			_, _ = B.Send(m.Chat, tr(lang, "invalid_sub_id"))
 			return
 		}
 
 		sub, err := model.GetSubscribeByID(subID)
 
 		if err != nil || sub == nil {
-			_, _ = B.Send(m.Chat, "Ingrese el ID de suscripción correcto!")
+			_, _ = B.Send(m.Chat, tr(lang, "invalid_sub_id"))
 			return
 		}
 
-		if !checkPermit(int64(m.Sender.ID), sub.UserID) {
-			_, _ = B.Send(m.Chat, "Permiso denegado!")
+		if !authOwner(m, lang, sub.UserID) {
 			return
 		}
 
 		_ = sub.SetInterval(interval)
 
 	}
-	_, _ = B.Send(m.Chat, "La frecuencia de rastreo se estableció correctamente!")
+	_, _ = B.Send(m.Chat, tr(lang, "setinterval_success"))
 
 	return
 }
 
 func activeAllCmdCtr(m *tb.Message) {
-	mention := GetMentionFromMessage(m)
-	if mention != "" {
-		channelChat, err := B.ChatByID(mention)
-		if err != nil {
-			_, _ = B.Send(m.Chat, "error")
-			return
-		}
-		adminList, err := B.AdminsOf(channelChat)
-		if err != nil {
-			_, _ = B.Send(m.Chat, "error")
-			return
-		}
-
-		senderIsAdmin := false
-		for _, admin := range adminList {
-			if m.Sender.ID == admin.User.ID {
-				senderIsAdmin = true
-			}
-		}
-
-		if !senderIsAdmin {
-			_, _ = B.Send(m.Chat, fmt.Sprintf("Los administradores que no son de canal no pueden realizar esta operación"))
-			return
-		}
+	lang := msgLang(m)
+	target := authTarget(m, lang, GetMentionFromMessage(m))
+	if target == nil {
+		return
+	}
 
+	if target.ID != m.Chat.ID {
+		channelChat := target
+		// also restarts the sources stopped after too many errors
 		_ = model.ActiveSourcesByUserID(channelChat.ID)
-		message := fmt.Sprintf("Canal [%s](https://t.me/%s) Todas las suscripciones están activadas", channelChat.Title, channelChat.Username)
+		_ = setSubsPausedByUserID(channelChat.ID, false)
+		message := tr(lang, "activeall_channel", channelChat.Title, channelChat.Username)
 
 		_, _ = B.Send(m.Chat, message, &tb.SendOptions{
 			DisableWebPagePreview: true,
@@ -957,8 +734,10 @@ func activeAllCmdCtr(m *tb.Message) {
 		})
 
 	} else {
+		// also restarts the sources stopped after too many errors
 		_ = model.ActiveSourcesByUserID(m.Chat.ID)
-		message := "Todas las suscripciones están activadas"
+		_ = setSubsPausedByUserID(m.Chat.ID, false)
+		message := tr(lang, "activeall")
 
 		_, _ = B.Send(m.Chat, message, &tb.SendOptions{
 			DisableWebPagePreview: true,
@@ -969,33 +748,16 @@ func activeAllCmdCtr(m *tb.Message) {
 }
 
 func pauseAllCmdCtr(m *tb.Message) {
-	mention := GetMentionFromMessage(m)
-	if mention != "" {
-		channelChat, err := B.ChatByID(mention)
-		if err != nil {
-			_, _ = B.Send(m.Chat, "error")
-			return
-		}
-		adminList, err := B.AdminsOf(channelChat)
-		if err != nil {
-			_, _ = B.Send(m.Chat, "error")
-			return
-		}
-
-		senderIsAdmin := false
-		for _, admin := range adminList {
-			if m.Sender.ID == admin.User.ID {
-				senderIsAdmin = true
-			}
-		}
-
-		if !senderIsAdmin {
-			_, _ = B.Send(m.Chat, fmt.Sprintf("Los administradores que no son de canal no pueden realizar esta operación"))
-			return
-		}
+	lang := msgLang(m)
+	target := authTarget(m, lang, GetMentionFromMessage(m))
+	if target == nil {
+		return
+	}
 
-		_ = model.PauseSourcesByUserID(channelChat.ID)
-		message := fmt.Sprintf("Canal [%s](https://t.me/%s) Todas las suscripciones están suspendidas", channelChat.Title, channelChat.Username)
+	if target.ID != m.Chat.ID {
+		channelChat := target
+		_ = setSubsPausedByUserID(channelChat.ID, true)
+		message := tr(lang, "pauseall_channel", channelChat.Title, channelChat.Username)
 
 		_, _ = B.Send(m.Chat, message, &tb.SendOptions{
 			DisableWebPagePreview: true,
@@ -1003,8 +765,8 @@ func pauseAllCmdCtr(m *tb.Message) {
 		})
 
 	} else {
-		_ = model.PauseSourcesByUserID(m.Chat.ID)
-		message := "Todas las suscripciones están suspendidas"
+		_ = setSubsPausedByUserID(m.Chat.ID, true)
+		message := tr(lang, "pauseall")
 
 		_, _ = B.Send(m.Chat, message, &tb.SendOptions{
 			DisableWebPagePreview: true,
@@ -1015,136 +777,166 @@ func pauseAllCmdCtr(m *tb.Message) {
 }
 
 func textCtr(m *tb.Message) {
-	switch UserState[m.Chat.ID] {
+	lang := msgLang(m)
+	state := chatStates.Get(m.Chat.ID)
+	switch state.Status {
+	case fsm.Sub, fsm.UnSub, fsm.Set:
+		// the conversation is kept per chat, in groups only the admins may answer it
+		if !canManageHere(m) {
+			return
+		}
+	}
+
+	switch state.Status {
 	case fsm.UnSub:
 		{
 			str := strings.Split(m.Text, " ")
 
 			if len(str) < 2 && (strings.HasPrefix(str[0], "[") && strings.HasSuffix(str[0], "]")) {
-				_, _ = B.Send(m.Chat, "Elija la instrucción correcta！")
+				_, _ = B.Send(m.Chat, tr(lang, "choose_correct"))
 			} else {
 
 				var sourceID uint
 				if _, err := fmt.Sscanf(str[0], "[%d]", &sourceID); err != nil {
-					_, _ = B.Send(m.Chat, "Elija la instrucción correcta！")
+					_, _ = B.Send(m.Chat, tr(lang, "choose_correct"))
 					return
 				}
 
 				source, err := model.GetSourceById(sourceID)
 
 				if err != nil {
-					_, _ = B.Send(m.Chat, "Elija la instrucción correcta！")
+					_, _ = B.Send(m.Chat, tr(lang, "choose_correct"))
 					return
 				}
 
-				err = model.UnsubByUserIDAndSource(m.Chat.ID, source)
+				err = unsubSource(m.Chat.ID, source)
 
 				if err != nil {
-					_, _ = B.Send(m.Chat, "Elija la instrucción correcta！")
+					_, _ = B.Send(m.Chat, tr(lang, "choose_correct"))
 					return
 				}
 
 				_, _ = B.Send(
 					m.Chat,
-					fmt.Sprintf("[%s](%s) Darse de baja con éxito", source.Title, source.Link),
+					tr(lang, "unsub_success", source.Title, source.Link),
 					&tb.SendOptions{
 						ParseMode: tb.ModeMarkdown,
 					}, &tb.ReplyMarkup{
 						ReplyKeyboardRemove: true,
 					},
 				)
-				UserState[m.Chat.ID] = fsm.None
+				chatStates.Clear(m.Chat.ID)
 				return
 			}
 		}
 
 	case fsm.Sub:
 		{
-			url := strings.Split(m.Text, " ")
-			if !CheckURL(url[0]) {
-				_, _ = B.Send(m.Chat, "Responde a la URL correcta.", &tb.ReplyMarkup{ForceReply: true})
+			urls := subURLs(m.Text)
+			if len(urls) == 0 {
+				_, _ = B.Send(m.Chat, tr(lang, "sub_reply_correct"), &tb.ReplyMarkup{ForceReply: true})
+				return
+			}
+
+			args := strings.Fields(m.Text)
+			if len(urls) > 1 && hasBackfillArg(args) {
+				_, _ = B.Send(m.Chat, tr(lang, "backfill_single_url"), &tb.ReplyMarkup{ForceReply: true})
+				return
+			}
+			backfill, ok := backfillArg(args, chatOptions.Get(m.Chat.ID).Backfill)
+			if !ok {
+				_, _ = B.Send(m.Chat, tr(lang, "backfill_usage", maxBackfill), &tb.ReplyMarkup{ForceReply: true})
 				return
 			}
 
-			registFeed(m.Chat, url[0])
-			UserState[m.Chat.ID] = fsm.None
+			if len(urls) == 1 {
+				subscribeURL(m, lang, m.Chat, urls[0], backfill)
+			} else {
+				subscribeURLs(m, lang, m.Chat.ID, urls)
+			}
+			chatStates.Clear(m.Chat.ID)
 		}
 	case fsm.SetSubTag:
 		{
-			return
+			payload := strings.SplitN(state.Payload, ":", 3)
+			if len(payload) != 3 {
+				chatStates.Clear(m.Chat.ID)
+				return
+			}
+			subID, _ := strconv.Atoi(payload[0])
+			chatID, _ := strconv.ParseInt(payload[1], 10, 64)
+
+			sub, err := model.GetSubscribeByID(subID)
+			if err != nil || sub == nil {
+				_, _ = B.Send(m.Chat, tr(lang, "invalid_sub_id"))
+				chatStates.Clear(m.Chat.ID)
+				return
+			}
+
+			if !authOwner(m, lang, sub.UserID) {
+				// the next message would be read as the tag
+				chatStates.Clear(m.Chat.ID)
+				return
+			}
+
+			tags := strings.Fields(strings.ReplaceAll(m.Text, "#", " "))
+			if len(tags) == 0 || len(tags) > 3 {
+				_, _ = B.Send(m.Chat, tr(lang, "set_tag_reply", sub.ID), &tb.ReplyMarkup{ForceReply: true})
+				return
+			}
+
+			if err := sub.SetTag(tags); err != nil {
+				_, _ = B.Send(m.Chat, tr(lang, "settag_failed"))
+				return
+			}
+			chatStates.Clear(m.Chat.ID)
+
+			// the setting card is only refreshed while the source still exists
+			if source, err := model.GetSourceById(sub.SourceID); err == nil && source != nil {
+				_, _ = B.Edit(
+					tb.StoredMessage{MessageID: payload[2], ChatID: chatID},
+					renderFeedSetting(lang, source, sub),
+					&tb.SendOptions{
+						ParseMode: tb.ModeHTML,
+					}, &tb.ReplyMarkup{
+						InlineKeyboard: genFeedSetBtn(lang, chatID, sub, source),
+					},
+				)
+			}
+			_, _ = B.Send(m.Chat, tr(lang, "settag_success"))
 		}
 	case fsm.Set:
 		{
 			str := strings.Split(m.Text, " ")
 			url := str[len(str)-1]
 			if len(str) != 2 && !CheckURL(url) {
-				_, _ = B.Send(m.Chat, "Elija la instrucción correcta！")
+				_, _ = B.Send(m.Chat, tr(lang, "choose_correct"))
 			} else {
 				source, err := model.GetSourceByUrl(url)
 
 				if err != nil {
-					_, _ = B.Send(m.Chat, "Elija la instrucción correcta！")
+					_, _ = B.Send(m.Chat, tr(lang, "choose_correct"))
 					return
 				}
 				sub, err := model.GetSubscribeByUserIDAndSourceID(m.Chat.ID, source.ID)
 				if err != nil {
-					_, _ = B.Send(m.Chat, "Elija la instrucción correcta！")
+					_, _ = B.Send(m.Chat, tr(lang, "choose_correct"))
 					return
 				}
-				t := template.New("setting template")
-				_, _ = t.Parse(feedSettingTmpl)
-
-				toggleNoticeKey := tb.InlineButton{
-					Unique: "set_toggle_notice_btn",
-					Text:   "Activar notificación",
-				}
-				if sub.EnableNotification == 1 {
-					toggleNoticeKey.Text = "Cerrar notificación"
-				}
-
-				toggleTelegraphKey := tb.InlineButton{
-					Unique: "set_toggle_telegraph_btn",
-					Text:   "Activar la transcodificación Telegraph",
-				}
-				if sub.EnableTelegraph == 1 {
-					toggleTelegraphKey.Text = "Desactivar la transcodificación Telegraph"
-				}
-
-				toggleEnabledKey := tb.InlineButton{
-					Unique: "set_toggle_update_btn",
-					Text:   "Pausar actualización",
-				}
-
-				if source.ErrorCount >= config.ErrorThreshold {
-					toggleEnabledKey.Text = "Reiniciar actualización"
-				}
-
-				feedSettingKeys := [][]tb.InlineButton{
-					[]tb.InlineButton{
-						toggleEnabledKey,
-						toggleNoticeKey,
-						toggleTelegraphKey,
-					},
-				}
-
-				text := new(bytes.Buffer)
-
-				_ = t.Execute(text, map[string]interface{}{"source": source, "sub": sub, "Count": config.ErrorThreshold})
-
 				// send null message to remove old keyboard
-				delKeyMessage, err := B.Send(m.Chat, "processing", &tb.ReplyMarkup{ReplyKeyboardRemove: true})
+				delKeyMessage, err := B.Send(m.Chat, tr(lang, "processing"), &tb.ReplyMarkup{ReplyKeyboardRemove: true})
 				err = B.Delete(delKeyMessage)
 
 				_, _ = B.Send(
 					m.Chat,
-					text.String(),
+					renderFeedSetting(lang, source, sub),
 					&tb.SendOptions{
 						ParseMode: tb.ModeHTML,
 					}, &tb.ReplyMarkup{
-						InlineKeyboard: feedSettingKeys,
+						InlineKeyboard: genFeedSetBtn(lang, m.Chat.ID, sub, source),
 					},
 				)
-				UserState[m.Chat.ID] = fsm.None
+				chatStates.Clear(m.Chat.ID)
 			}
 		}
 	}
@@ -1152,108 +944,33 @@ func textCtr(m *tb.Message) {
 
 // docCtr Document handler
 func docCtr(m *tb.Message) {
-	if m.FromGroup() {
-		if !userIsAdminOfGroup(m.Sender.ID, m.Chat) {
-			return
-		}
-	}
-
-	if m.FromChannel() {
-		if !UserIsAdminChannel(m.ID, m.Chat) {
-			return
-		}
+	lang := msgLang(m)
+	target := authTarget(m, lang, GetMentionFromMessage(m))
+	if target == nil {
+		return
 	}
 
 	url, _ := B.FileURLByID(m.Document.FileID)
-	if !strings.HasSuffix(url, ".opml") {
-		B.Send(m.Chat, "Si necesita importar suscripciones, envíe el archivo OPML correcto.")
+	data, err := fetchImportFile(url)
+	if err != nil {
+		zap.S().Warnf("download import file failed, err:%+v", err)
+		_, _ = B.Send(m.Chat, tr(lang, "import_download_failed"))
 		return
 	}
 
-	opml, err := GetOPMLByURL(url)
+	// the format is sniffed from the content, OPML folders and reader categories become subscription tags
+	items, format, err := parseImportFile(data)
 	if err != nil {
-		if err.Error() == "fetch opml file error" {
-			_, _ = B.Send(m.Chat,
-				"No se pudo descargar el archivo OPML. Verifique si el servidor bot puede conectarse al servidor de Telegram o intente importarlo más tarde. Código de error 02")
-
-		} else {
-			_, _ = B.Send(
-				m.Chat,
-				fmt.Sprintf(
-					"Si necesita importar suscripciones, envíe el archivo OPML correcto. Código de error 01, doc mimetype: %s",
-					m.Document.MIME),
-			)
-		}
+		zap.S().Warnf("parse import file %q failed, err:%+v", m.Document.FileName, err)
+		_, _ = B.Send(
+			m.Chat,
+			tr(lang, "import_bad_file", m.Document.MIME),
+		)
 		return
 	}
 
-	userID := m.Chat.ID
-	mention := GetMentionFromMessage(m)
-	if mention != "" {
-		// import for channel
-		channelChat, err := B.ChatByID(mention)
-		if err != nil {
-			_, _ = B.Send(m.Chat, "Obtenga el error de información del canal, verifique si la identificación del canal es correcta")
-			return
-		}
-
-		if !checkPermitOfChat(int64(m.Sender.ID), channelChat) {
-			_, _ = B.Send(m.Chat, fmt.Sprintf("Los administradores que no son de canal no pueden realizar esta operación"))
-			return
-		}
-
-		userID = channelChat.ID
-	}
-
-	message, _ := B.Send(m.Chat, "Procesando .. por favor espere...")
-	outlines, _ := opml.GetFlattenOutlines()
-	var failImportList []Outline
-	var successImportList []Outline
-
-	for _, outline := range outlines {
-		source, err := model.FindOrNewSourceByUrl(outline.XMLURL)
-		if err != nil {
-			failImportList = append(failImportList, outline)
-			continue
-		}
-		err = model.RegistFeed(userID, source.ID)
-		if err != nil {
-			failImportList = append(failImportList, outline)
-			continue
-		}
-		zap.S().Infof("%d subscribe [%d]%s %s", m.Chat.ID, source.ID, source.Title, source.Link)
-		successImportList = append(successImportList, outline)
-	}
-
-	importReport := fmt.Sprintf("<b>Importación exitosa: %d, error de importación：%d</b>", len(successImportList), len(failImportList))
-	if len(successImportList) != 0 {
-		successReport := "\n\n<b>Los siguientes feeds se importaron correctamente:</b>"
-		for i, line := range successImportList {
-			if line.Text != "" {
-				successReport += fmt.Sprintf("\n[%d] <a href=\"%s\">%s</a>", i+1, line.XMLURL, line.Text)
-			} else {
-				successReport += fmt.Sprintf("\n[%d] %s", i+1, line.XMLURL)
-			}
-		}
-		importReport += successReport
-	}
-
-	if len(failImportList) != 0 {
-		failReport := "\n\n<b>No se pudo importar el siguiente feed:</b>"
-		for i, line := range failImportList {
-			if line.Text != "" {
-				failReport += fmt.Sprintf("\n[%d] <a href=\"%s\">%s</a>", i+1, line.XMLURL, line.Text)
-			} else {
-				failReport += fmt.Sprintf("\n[%d] %s", i+1, line.XMLURL)
-			}
-		}
-		importReport += failReport
-	}
-
-	_, _ = B.Edit(message, importReport, &tb.SendOptions{
-		DisableWebPagePreview: true,
-		ParseMode:             tb.ModeHTML,
-	})
+	// import for the channel when the target is one
+	sendImportPreview(m, lang, target.ID, format, items)
 }
 
 func errorCtr(m *tb.Message, errMsg string) {
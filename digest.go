@@ -0,0 +1,406 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/indes/flowerss-bot/config"
+	"github.com/indes/flowerss-bot/model"
+	"github.com/indes/flowerss-bot/tgraph"
+	"go.uber.org/zap"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	digestQueueKey   = "digest_queue"
+	digestMaxLength  = 4096
+	defaultDigestDay = time.Monday
+)
+
+type deliveryMode string
+
+const (
+	// deliveryInherit is the mode of the subscriptions without their own, they follow the mode of their tags
+	deliveryInherit   deliveryMode = ""
+	deliveryImmediate deliveryMode = "immediate"
+	deliveryHourly    deliveryMode = "hourly"
+	deliveryDaily     deliveryMode = "daily"
+	deliveryWeekly    deliveryMode = "weekly"
+)
+
+var deliveryModes = []deliveryMode{deliveryInherit, deliveryImmediate, deliveryHourly, deliveryDaily, deliveryWeekly}
+
+// parseDeliveryMode parses the name of a mode, deliveryInherit has none
+func parseDeliveryMode(s string) (deliveryMode, bool) {
+	for _, mode := range deliveryModes {
+		if mode != deliveryInherit && string(mode) == s {
+			return mode, true
+		}
+	}
+	return deliveryInherit, false
+}
+
+// next returns the mode following m, used by the /set card button to cycle through the modes
+func (m deliveryMode) next() deliveryMode {
+	for i, mode := range deliveryModes {
+		if mode == m {
+			return deliveryModes[(i+1)%len(deliveryModes)]
+		}
+	}
+	return deliveryInherit
+}
+
+func (m deliveryMode) name(lang string) string {
+	if m == deliveryInherit {
+		return tr(lang, "delivery_inherit")
+	}
+	return tr(lang, "delivery_"+string(m))
+}
+
+// subTags splits model.Subscribe.Tag ("#a #b") into tag names
+func subTags(sub *model.Subscribe) []string {
+	var tags []string
+	for _, tag := range strings.Fields(sub.Tag) {
+		if tag = strings.TrimLeft(tag, "#"); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// deliveryModeOf returns the mode of sub, falling back to the mode of its tags in the chat, then to immediate
+func deliveryModeOf(sub *model.Subscribe, opt SubOption) deliveryMode {
+	if opt.Delivery != deliveryInherit {
+		return opt.Delivery
+	}
+	tagModes := chatOptions.Get(sub.UserID).TagDelivery
+	for _, tag := range subTags(sub) {
+		if mode, ok := tagModes[tag]; ok {
+			return mode
+		}
+	}
+	return deliveryImmediate
+}
+
+// chatLocation returns the time zone chosen with /digest tz, UTC by default
+func chatLocation(opt ChatOption) *time.Location {
+	if opt.TimeZone != "" {
+		if loc, err := time.LoadLocation(opt.TimeZone); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// lastDigestSlot returns the latest time before now at which a digest of mode was due
+func lastDigestSlot(opt ChatOption, mode deliveryMode, now time.Time) time.Time {
+	now = now.In(chatLocation(opt))
+	switch mode {
+	case deliveryHourly:
+		return now.Truncate(time.Hour)
+	case deliveryDaily, deliveryWeekly:
+		slot := time.Date(now.Year(), now.Month(), now.Day(), opt.DigestHour, opt.DigestMinute, 0, 0, now.Location())
+		if slot.After(now) {
+			slot = slot.AddDate(0, 0, -1)
+		}
+		if mode == deliveryWeekly {
+			day := defaultDigestDay
+			if opt.DigestWeekday != nil {
+				day = *opt.DigestWeekday
+			}
+			for slot.Weekday() != day {
+				slot = slot.AddDate(0, 0, -1)
+			}
+		}
+		return slot
+	}
+	return now
+}
+
+type digestItem struct {
+	SubID       uint         `json:"sub_id"`
+	Mode        deliveryMode `json:"mode"`
+	SourceTitle string       `json:"source_title"`
+	Title       string       `json:"title"`
+	Link        string       `json:"link"`
+	QueuedAt    time.Time    `json:"queued_at"`
+}
+
+type digestStore struct {
+	once  sync.Once
+	mu    sync.Mutex
+	items map[int64][]digestItem
+	// dirty is set by Push, flush saves the queue
+	dirty bool
+}
+
+var digestQueue = &digestStore{}
+
+func (s *digestStore) load() {
+	s.once.Do(func() {
+		s.items = make(map[int64][]digestItem)
+		if err := persister.Load(digestQueueKey, &s.items); err != nil {
+			zap.S().Errorf("load digest queue failed, err:%+v", err)
+		}
+	})
+}
+
+// Push queues content for the next digest of sub's chat, the queue is saved by the next flush
+func (s *digestStore) Push(source *model.Source, sub *model.Subscribe, content *model.Content, mode deliveryMode) {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[sub.UserID] = append(s.items[sub.UserID], digestItem{
+		SubID:       sub.ID,
+		Mode:        mode,
+		SourceTitle: source.Title,
+		Title:       content.Title,
+		Link:        content.RawLink,
+		QueuedAt:    time.Now(),
+	})
+	s.dirty = true
+}
+
+// PopDue removes and returns, per chat, the queued items whose digest is due at now
+func (s *digestStore) PopDue(now time.Time) map[int64][]digestItem {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := make(map[int64][]digestItem)
+	for chatID, items := range s.items {
+		opt := chatOptions.Get(chatID)
+
+		// a mode is due when its last slot passed after the oldest queued item
+		dueModes := make(map[deliveryMode]bool)
+		for _, item := range items {
+			if _, checked := dueModes[item.Mode]; !checked {
+				dueModes[item.Mode] = lastDigestSlot(opt, item.Mode, now).After(item.QueuedAt)
+			}
+		}
+
+		var rest []digestItem
+		for _, item := range items {
+			if dueModes[item.Mode] {
+				due[chatID] = append(due[chatID], item)
+			} else {
+				rest = append(rest, item)
+			}
+		}
+		if len(rest) == 0 {
+			delete(s.items, chatID)
+		} else {
+			s.items[chatID] = rest
+		}
+	}
+
+	if len(due) > 0 {
+		s.save()
+	}
+	return due
+}
+
+// Requeue puts back the items of a digest that could not be sent, they are due again at the next check
+func (s *digestStore) Requeue(chatID int64, items []digestItem) {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[chatID] = append(append([]digestItem(nil), items...), s.items[chatID]...)
+	s.save()
+}
+
+func (s *digestStore) flush() {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dirty {
+		s.save()
+	}
+}
+
+// save must be called with s.mu held
+func (s *digestStore) save() {
+	if err := persister.Save(digestQueueKey, s.items); err != nil {
+		zap.S().Errorf("save digest queue failed, err:%+v", err)
+		return
+	}
+	s.dirty = false
+}
+
+// digestLoop sends the due digests every minute
+func digestLoop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		for chatID, items := range digestQueue.PopDue(now) {
+			sendDigest(chatID, items)
+		}
+	}
+}
+
+func renderDigest(lang string, items []digestItem) string {
+	sort.SliceStable(items, func(i, j int) bool { return items[i].SourceTitle < items[j].SourceTitle })
+
+	msg := tr(lang, "digest_title", len(items))
+	lastSource := ""
+	for i, item := range items {
+		if i == 0 || item.SourceTitle != lastSource {
+			msg += fmt.Sprintf("\n\n<b>%s</b>", html.EscapeString(item.SourceTitle))
+			lastSource = item.SourceTitle
+		}
+		msg += fmt.Sprintf("\n• <a href=\"%s\">%s</a>", html.EscapeString(item.Link), html.EscapeString(item.Title))
+	}
+	return msg
+}
+
+func sendDigest(chatID int64, items []digestItem) {
+	lang := chatLang(&tb.Chat{ID: chatID}, nil)
+	chat := &tb.Chat{ID: chatID}
+	msg := renderDigest(lang, items)
+
+	if len(msg) > digestMaxLength && config.EnableTelegraph {
+		title := tr(lang, "digest_page_title", time.Now().In(chatLocation(chatOptions.Get(chatID))).Format("2006-01-02 15:04"))
+		url, err := tgraph.PublishHtml(title, title, "", strings.Replace(msg, "\n", "<br>", -1))
+		if err == nil {
+			msg = fmt.Sprintf("<a href=\"%s\">%s</a>", url, html.EscapeString(title))
+		} else {
+			zap.S().Warnf("publish digest of %d to telegraph failed, err:%+v", chatID, err)
+		}
+	}
+
+	for i, part := range splitMessage(msg, digestMaxLength) {
+		_, err := B.Send(chat, part, &tb.SendOptions{
+			DisableWebPagePreview: true,
+			ParseMode:             tb.ModeHTML,
+		})
+		if err == nil {
+			continue
+		}
+		zap.S().Warnf("send digest to %d failed, err:%+v", chatID, err)
+		// retried when nothing was sent, unless the bot can no longer write to the chat
+		if i == 0 && !strings.Contains(err.Error(), "Forbidden") && !strings.Contains(err.Error(), "chat not found") {
+			digestQueue.Requeue(chatID, items)
+		}
+		return
+	}
+}
+
+// splitMessage cuts msg into parts of at most limit bytes at line breaks
+func splitMessage(msg string, limit int) []string {
+	var parts []string
+	for len(msg) > limit {
+		cut := strings.LastIndex(msg[:limit], "\n")
+		if cut <= 0 {
+			cut = limit
+		}
+		parts = append(parts, msg[:cut])
+		msg = strings.TrimLeft(msg[cut:], "\n")
+	}
+	return append(parts, msg)
+}
+
+func digestCmdCtr(m *tb.Message) {
+	lang := msgLang(m)
+	chat := authTarget(m, lang, GetMentionFromMessage(m))
+	if chat == nil {
+		return
+	}
+	args := commandArgs(m)
+
+	if len(args) == 0 {
+		_, _ = B.Send(m.Chat, digestSettingMessage(lang, chatOptions.Get(chat.ID)))
+		return
+	}
+
+	var update func(opt *ChatOption)
+	switch {
+	case args[0] == "tz" && len(args) == 2:
+		if _, err := time.LoadLocation(args[1]); err != nil {
+			_, _ = B.Send(m.Chat, tr(lang, "digest_bad_tz", args[1]))
+			return
+		}
+		update = func(opt *ChatOption) { opt.TimeZone = args[1] }
+	case args[0] == "time" && len(args) == 2:
+		t, err := time.Parse("15:04", args[1])
+		if err != nil {
+			_, _ = B.Send(m.Chat, tr(lang, "digest_usage"))
+			return
+		}
+		update = func(opt *ChatOption) { opt.DigestHour, opt.DigestMinute = t.Hour(), t.Minute() }
+	case args[0] == "day" && len(args) == 2:
+		day, ok := parseWeekday(args[1])
+		if !ok {
+			_, _ = B.Send(m.Chat, tr(lang, "digest_usage"))
+			return
+		}
+		update = func(opt *ChatOption) { opt.DigestWeekday = &day }
+	case args[0] == "tag" && len(args) == 3:
+		tag := strings.TrimLeft(args[1], "#")
+		mode, ok := parseDeliveryMode(args[2])
+		if tag == "" || !ok {
+			_, _ = B.Send(m.Chat, tr(lang, "digest_usage"))
+			return
+		}
+		update = func(opt *ChatOption) {
+			tagModes := make(map[string]deliveryMode, len(opt.TagDelivery)+1)
+			for k, v := range opt.TagDelivery {
+				tagModes[k] = v
+			}
+			if mode == deliveryImmediate {
+				delete(tagModes, tag)
+			} else {
+				tagModes[tag] = mode
+			}
+			opt.TagDelivery = tagModes
+		}
+	default:
+		_, _ = B.Send(m.Chat, tr(lang, "digest_usage"))
+		return
+	}
+
+	if err := chatOptions.Update(chat.ID, update); err != nil {
+		zap.S().Errorf("save digest setting of %d failed, err:%+v", chat.ID, err)
+		_, _ = B.Send(m.Chat, tr(lang, "digest_failed"))
+		return
+	}
+	_, _ = B.Send(m.Chat, digestSettingMessage(lang, chatOptions.Get(chat.ID)))
+}
+
+func parseWeekday(s string) (time.Weekday, bool) {
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 && n < 7 {
+		return time.Weekday(n), true
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.HasPrefix(strings.ToLower(d.String()), strings.ToLower(s)) && len(s) >= 3 {
+			return d, true
+		}
+	}
+	return time.Sunday, false
+}
+
+func digestSettingMessage(lang string, opt ChatOption) string {
+	day := defaultDigestDay
+	if opt.DigestWeekday != nil {
+		day = *opt.DigestWeekday
+	}
+	msg := tr(lang, "digest_setting", chatLocation(opt).String(), opt.DigestHour, opt.DigestMinute, day.String())
+
+	tags := make([]string, 0, len(opt.TagDelivery))
+	for tag := range opt.TagDelivery {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		msg += fmt.Sprintf("\n#%s: %s", tag, opt.TagDelivery[tag].name(lang))
+	}
+	return msg + "\n\n" + tr(lang, "digest_usage")
+}
@@ -0,0 +1,87 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLastDigestSlot(t *testing.T) {
+	monday := time.Monday
+	friday := time.Friday
+	// Wednesday
+	now := time.Date(2021, 3, 10, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		opt  ChatOption
+		mode deliveryMode
+		want time.Time
+	}{
+		{"hourly", ChatOption{}, deliveryHourly, time.Date(2021, 3, 10, 9, 0, 0, 0, time.UTC)},
+		{"daily passed", ChatOption{DigestHour: 8}, deliveryDaily, time.Date(2021, 3, 10, 8, 0, 0, 0, time.UTC)},
+		{"daily later today", ChatOption{DigestHour: 20, DigestMinute: 15}, deliveryDaily, time.Date(2021, 3, 9, 20, 15, 0, 0, time.UTC)},
+		{"weekly default day", ChatOption{DigestHour: 8}, deliveryWeekly, time.Date(2021, 3, 8, 8, 0, 0, 0, time.UTC)},
+		{"weekly monday", ChatOption{DigestHour: 8, DigestWeekday: &monday}, deliveryWeekly, time.Date(2021, 3, 8, 8, 0, 0, 0, time.UTC)},
+		{"weekly friday", ChatOption{DigestHour: 8, DigestWeekday: &friday}, deliveryWeekly, time.Date(2021, 3, 5, 8, 0, 0, 0, time.UTC)},
+		{"time zone", ChatOption{TimeZone: "Asia/Tokyo", DigestHour: 20}, deliveryDaily, time.Date(2021, 3, 9, 11, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lastDigestSlot(tt.opt, tt.mode, now); !got.Equal(tt.want) {
+				t.Errorf("lastDigestSlot() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDeliveryMode(t *testing.T) {
+	tests := []struct {
+		s    string
+		want deliveryMode
+		ok   bool
+	}{
+		{"immediate", deliveryImmediate, true},
+		{"hourly", deliveryHourly, true},
+		{"weekly", deliveryWeekly, true},
+		{"", deliveryInherit, false},
+		{"monthly", deliveryInherit, false},
+	}
+
+	for _, tt := range tests {
+		if got, ok := parseDeliveryMode(tt.s); got != tt.want || ok != tt.ok {
+			t.Errorf("parseDeliveryMode(%q) = %q, %v, want %q, %v", tt.s, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDeliveryModeNext(t *testing.T) {
+	mode := deliveryInherit
+	for range deliveryModes {
+		mode = mode.next()
+	}
+	if mode != deliveryInherit {
+		t.Errorf("cycling through the modes ended on %q", mode)
+	}
+	if got := deliveryInherit.next(); got != deliveryImmediate {
+		t.Errorf("deliveryInherit.next() = %q, want %q", got, deliveryImmediate)
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	msg := strings.Repeat("line\n", 10)
+	parts := splitMessage(msg, 12)
+	for _, part := range parts {
+		if len(part) > 12 {
+			t.Errorf("part %q is longer than the limit", part)
+		}
+	}
+	if got := strings.Join(parts, "\n"); got != strings.TrimSuffix(msg, "\n") && got != msg {
+		t.Errorf("splitMessage() lost text: %q", got)
+	}
+
+	long := strings.Repeat("x", 30)
+	if parts := splitMessage(long, 12); len(parts) != 3 || strings.Join(parts, "") != long {
+		t.Errorf("splitMessage(%q) = %q", long, parts)
+	}
+}
@@ -0,0 +1,246 @@
+package bot
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"go.uber.org/zap"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	// maxDiscoverPageSize limits the part of a page read to look for feeds
+	maxDiscoverPageSize = 1 << 20
+	// maxDiscoverCandidates is the number of feeds offered when a page has many
+	maxDiscoverCandidates = 8
+)
+
+// discoverPaths are tried when a page does not announce its feeds
+var discoverPaths = []string{"/feed", "/rss", "/rss.xml", "/atom.xml", "/feed.xml", "/index.xml", "/feed.json"}
+
+var feedMIMETypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+	"application/json":      true,
+	"application/xml":       true,
+	"text/xml":              true,
+	"application/rdf+xml":   true,
+}
+
+var (
+	linkTagRegexp   = regexp.MustCompile(`(?is)<link\s[^>]*>`)
+	tagAttrRegexp   = regexp.MustCompile(`(?is)([a-z-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+)`)
+	baseHrefRegexp  = regexp.MustCompile(`(?is)<base\s[^>]*href\s*=\s*["']([^"']+)["']`)
+	feedRootRegexps = []*regexp.Regexp{
+		regexp.MustCompile(`(?is)^(<\?xml[^>]*>\s*)?(<!--.*?-->\s*|<\?xml-stylesheet[^>]*>\s*|<!doctype[^>]*>\s*)*<(rss|feed|rdf:rdf)[\s>]`),
+		regexp.MustCompile(`(?s)^\{.*"version"\s*:\s*"https://jsonfeed\.org/version/`),
+	}
+)
+
+// feedCandidate is a feed found on a web page
+type feedCandidate struct {
+	Title string
+	URL   string
+}
+
+var discoverClient = &http.Client{Timeout: 15 * time.Second}
+
+// discoverFeeds returns the feeds of the page at link, nil when link is a feed itself or can not be read
+func discoverFeeds(link string) []feedCandidate {
+	base, err := url.Parse(link)
+	if err != nil {
+		return nil
+	}
+	body, contentType, err := fetchDiscoverPage(link)
+	if err != nil {
+		zap.S().Warnf("discover feeds of %s failed, err:%+v", link, err)
+		return nil
+	}
+	if isFeed(body, contentType) {
+		return nil
+	}
+
+	candidates := feedLinks(base, body)
+	if len(candidates) == 0 {
+		candidates = probeFeedPaths(base)
+	}
+	if len(candidates) > maxDiscoverCandidates {
+		candidates = candidates[:maxDiscoverCandidates]
+	}
+	return candidates
+}
+
+func fetchDiscoverPage(link string) ([]byte, string, error) {
+	resp, err := discoverClient.Get(link)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetch %s: %s", link, resp.Status)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDiscoverPageSize))
+	if err != nil {
+		return nil, "", err
+	}
+	return body, resp.Header.Get("Content-Type"), nil
+}
+
+// isFeed reports whether a response is a RSS, Atom or JSON feed, by its first tag or its content type.
+// The body is checked first, many servers send feeds as text/html or text/plain. A body gofeed can parse
+// is a feed whatever its prolog, the url the user gave is never replaced by another feed of the site.
+func isFeed(body []byte, contentType string) bool {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(body, utf8BOM))
+	for _, re := range feedRootRegexps {
+		if re.Match(trimmed) {
+			return true
+		}
+	}
+	if _, err := gofeed.NewParser().Parse(bytes.NewReader(trimmed)); err == nil {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/rss+xml", "application/atom+xml", "application/feed+json":
+		return true
+	}
+	return false
+}
+
+// feedLinks returns the <link rel="alternate"> feeds of an HTML page
+func feedLinks(base *url.URL, body []byte) []feedCandidate {
+	if m := baseHrefRegexp.FindSubmatch(body); m != nil {
+		if href, err := base.Parse(html.UnescapeString(string(m[1]))); err == nil {
+			base = href
+		}
+	}
+
+	var candidates []feedCandidate
+	seen := make(map[string]bool)
+	for _, tag := range linkTagRegexp.FindAll(body, -1) {
+		attrs := make(map[string]string)
+		for _, attr := range tagAttrRegexp.FindAllSubmatch(tag, -1) {
+			attrs[strings.ToLower(string(attr[1]))] = html.UnescapeString(strings.Trim(string(attr[2]), `"'`))
+		}
+		rel := strings.Fields(strings.ToLower(attrs["rel"]))
+		if !containsString(rel, "alternate") || !feedMIMETypes[strings.ToLower(attrs["type"])] || attrs["href"] == "" {
+			continue
+		}
+		href, err := base.Parse(attrs["href"])
+		if err != nil || seen[href.String()] {
+			continue
+		}
+		seen[href.String()] = true
+		candidates = append(candidates, feedCandidate{Title: strings.TrimSpace(attrs["title"]), URL: href.String()})
+	}
+	return candidates
+}
+
+// probeFeedPaths tries the usual feed paths of the site of base
+func probeFeedPaths(base *url.URL) []feedCandidate {
+	found := make([]*feedCandidate, len(discoverPaths))
+	var wg sync.WaitGroup
+	for i, path := range discoverPaths {
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			link := (&url.URL{Scheme: base.Scheme, Host: base.Host, Path: path}).String()
+			body, contentType, err := fetchDiscoverPage(link)
+			if err == nil && isFeed(body, contentType) {
+				found[i] = &feedCandidate{URL: link}
+			}
+		}(i, path)
+	}
+	wg.Wait()
+
+	var candidates []feedCandidate
+	for _, candidate := range found {
+		if candidate != nil {
+			candidates = append(candidates, *candidate)
+		}
+	}
+	return candidates
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// subscribeURL subscribes target to link, or to the feed of the web page at link, offering a choice
+// when the page has several feeds. The backfill latest items are sent to new subscriptions.
+func subscribeURL(m *tb.Message, lang string, target *tb.Chat, link string, backfill int) {
+	candidates := discoverFeeds(link)
+	switch len(candidates) {
+	case 0:
+		// a feed, or a page without feeds left to registFeed to report
+	case 1:
+		link = candidates[0].URL
+	default:
+		sendFeedChoice(m, lang, target, link, candidates, backfill)
+		return
+	}
+
+	subscribeWithBackfill(target.ID, link, backfill, func() {
+		if target.ID == m.Chat.ID {
+			registFeed(m.Chat, link)
+		} else {
+			FeedForChannelRegister(m, link, chatRef(target))
+		}
+	})
+}
+
+func sendFeedChoice(m *tb.Message, lang string, target *tb.Chat, page string, candidates []feedCandidate, backfill int) {
+	var rows [][]tb.InlineButton
+	for _, candidate := range candidates {
+		text := candidate.Title
+		if text == "" {
+			text = candidate.URL
+		}
+		rows = append(rows, []tb.InlineButton{
+			tb.InlineButton{
+				Unique: "sub_discover_btn",
+				Text:   text,
+				Data:   newCallback(m.Chat.ID, callbackPayload{Owner: target.ID, Kind: "discover", Arg: candidate.URL, Backfill: backfill}),
+			},
+		})
+	}
+	_, _ = B.Send(m.Chat, tr(lang, "sub_discover_choose", page), &tb.SendOptions{DisableWebPagePreview: true}, &tb.ReplyMarkup{InlineKeyboard: rows})
+}
+
+func subDiscoverBtnCtr(c *tb.Callback) {
+	payload, ok := loadCallback(c)
+	if !ok || payload.Kind != "discover" || !callbackAuth(c, payload.Owner) {
+		return
+	}
+	_ = B.Respond(c)
+	lang := cbLang(c)
+
+	var err error
+	subscribeWithBackfill(payload.Owner, payload.Arg, payload.Backfill, func() {
+		err = importFeed(payload.Owner, importItem{URL: payload.Arg})
+	})
+	if err != nil {
+		zap.S().Warnf("subscribe %d to %s failed, err:%+v", payload.Owner, payload.Arg, err)
+		_, _ = B.Edit(c.Message, tr(lang, "sub_discover_failed", payload.Arg), &tb.SendOptions{DisableWebPagePreview: true})
+		return
+	}
+	_, _ = B.Edit(c.Message, tr(lang, "sub_discover_success", payload.Arg), &tb.SendOptions{DisableWebPagePreview: true})
+}
@@ -0,0 +1,36 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsFeed(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		want        bool
+	}{
+		{"rss as html", `<?xml version="1.0" encoding="UTF-8"?><rss version="2.0"><channel></channel></rss>`, "text/html; charset=utf-8", true},
+		{"atom with stylesheet", "\xef\xbb\xbf<?xml version=\"1.0\"?>\n<?xml-stylesheet href=\"feed.xsl\"?>\n<!-- feed -->\n<feed xmlns=\"http://www.w3.org/2005/Atom\">", "text/plain", true},
+		{"rdf", `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">`, "application/xml", true},
+		{"json feed", `{"version": "https://jsonfeed.org/version/1.1", "title": "Example"}`, "application/json", true},
+		{"rss 0.91 with doctype", `<?xml version="1.0" encoding="ISO-8859-1"?>
+<!DOCTYPE rss PUBLIC "-//Netscape Communications//DTD RSS 0.91//EN" "http://my.netscape.com/publish/formats/rss-0.91.dtd">
+<rss version="0.91"><channel><title>Example</title><link>https://example.com/</link></channel></rss>`, "text/xml", true},
+		{"rss after a long comment", "<?xml version=\"1.0\"?>\n<!-- generated by example -->\n<!-- " + strings.Repeat("x", 100) + " -->\n<rss version=\"2.0\"><channel><title>Example</title></channel></rss>", "text/xml", true},
+		{"content type only", "", "application/atom+xml", true},
+		{"html page", `<!DOCTYPE html><html><head><link rel="alternate" type="application/rss+xml" href="/rss"></head></html>`, "text/html", false},
+		{"other json", `[{"version": "1.0"}]`, "application/json", false},
+		{"sitemap", `<?xml version="1.0"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"></urlset>`, "text/xml", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFeed([]byte(tt.body), tt.contentType); got != tt.want {
+				t.Errorf("isFeed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
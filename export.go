@@ -0,0 +1,254 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/indes/flowerss-bot/model"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	exportFormatName    = "flowerss-export"
+	exportFormatVersion = 1
+)
+
+var exportCSVHeader = []string{"url", "title", "tags", "interval", "notification", "telegraph", "paused", "delivery", "filters"}
+
+// subSettings are the settings of a subscription carried by JSON and CSV exports
+type subSettings struct {
+	Interval     int      `json:"interval"`
+	Notification bool     `json:"notification"`
+	Telegraph    bool     `json:"telegraph"`
+	Paused       bool     `json:"paused"`
+	Delivery     string   `json:"delivery"`
+	Filters      []string `json:"filters,omitempty"`
+}
+
+type exportRecord struct {
+	URL   string   `json:"url"`
+	Title string   `json:"title"`
+	Tags  []string `json:"tags,omitempty"`
+	subSettings
+}
+
+type exportDocument struct {
+	Format        string         `json:"format"`
+	Version       int            `json:"version"`
+	Subscriptions []exportRecord `json:"subscriptions"`
+}
+
+func isExportFormat(format string) bool {
+	return format == "opml" || format == "json" || format == "csv"
+}
+
+// exportDocumentOf returns the file of list in format, opml, json or csv
+func exportDocumentOf(list []subSource, format string) (*tb.Document, error) {
+	var content string
+	var err error
+	switch format {
+	case "opml":
+		// subscriptions are grouped in folders by tag
+		content, err = toTaggedOPML(list)
+	case "json":
+		content, err = toExportJSON(list)
+	case "csv":
+		content, err = toExportCSV(list)
+	default:
+		err = fmt.Errorf("unknown export format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &tb.Document{File: tb.FromReader(strings.NewReader(content))}
+	doc.FileName = fmt.Sprintf("subscriptions_%d.%s", time.Now().Unix(), format)
+	return doc, nil
+}
+
+func exportRecords(list []subSource) []exportRecord {
+	records := make([]exportRecord, 0, len(list))
+	for _, item := range list {
+		opt := subOptions.Get(item.sub.ID)
+		var filters []string
+		for _, rule := range opt.Filters {
+			filters = append(filters, rule.String())
+		}
+
+		records = append(records, exportRecord{
+			URL:   item.source.Link,
+			Title: item.source.Title,
+			Tags:  subTags(&item.sub),
+			subSettings: subSettings{
+				Interval:     item.sub.Interval,
+				Notification: item.sub.EnableNotification == 1,
+				Telegraph:    item.sub.EnableTelegraph == 1,
+				Paused:       opt.Paused,
+				Delivery:     string(opt.Delivery),
+				Filters:      filters,
+			},
+		})
+	}
+	return records
+}
+
+// toExportJSON writes list with the settings of every subscription
+func toExportJSON(list []subSource) (string, error) {
+	doc := exportDocument{Format: exportFormatName, Version: exportFormatVersion, Subscriptions: exportRecords(list)}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// toExportCSV writes list with the settings of every subscription, tags are separated by spaces
+// and filters by new lines
+func toExportCSV(list []subSource) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(exportCSVHeader); err != nil {
+		return "", err
+	}
+	for _, r := range exportRecords(list) {
+		err := w.Write([]string{
+			r.URL,
+			r.Title,
+			strings.Join(r.Tags, " "),
+			strconv.Itoa(r.Interval),
+			strconv.FormatBool(r.Notification),
+			strconv.FormatBool(r.Telegraph),
+			strconv.FormatBool(r.Paused),
+			r.Delivery,
+			strings.Join(r.Filters, "\n"),
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	return buf.String(), w.Error()
+}
+
+func (r exportRecord) importItem() importItem {
+	settings := r.subSettings
+	return importItem{Title: r.Title, URL: r.URL, Tags: importTags(r.Tags), Settings: &settings}
+}
+
+// exportJSONImporter reads back the files of /export json
+type exportJSONImporter struct{}
+
+func (exportJSONImporter) Name() string { return "flowerss JSON" }
+
+func (exportJSONImporter) Sniff(data []byte) bool {
+	var doc exportDocument
+	return json.Unmarshal(data, &doc) == nil && doc.Format == exportFormatName
+}
+
+func (exportJSONImporter) Parse(data []byte) ([]importItem, error) {
+	var doc exportDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Version > exportFormatVersion {
+		return nil, fmt.Errorf("unsupported export version %d", doc.Version)
+	}
+	items := make([]importItem, 0, len(doc.Subscriptions))
+	for _, r := range doc.Subscriptions {
+		items = append(items, r.importItem())
+	}
+	return items, nil
+}
+
+// exportCSVImporter reads back the files of /export csv
+type exportCSVImporter struct{}
+
+func (exportCSVImporter) Name() string { return "flowerss CSV" }
+
+func (exportCSVImporter) Sniff(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(strings.Join(exportCSVHeader, ",")))
+}
+
+func (exportCSVImporter) Parse(data []byte) ([]importItem, error) {
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []importItem
+	for i, row := range rows[1:] {
+		if len(row) != len(exportCSVHeader) {
+			return nil, fmt.Errorf("line %d: expected %d fields, got %d", i+2, len(exportCSVHeader), len(row))
+		}
+		r := exportRecord{URL: row[0], Title: row[1], Tags: strings.Fields(row[2])}
+		if r.Interval, err = strconv.Atoi(row[3]); err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+2, err)
+		}
+		if r.Notification, err = strconv.ParseBool(row[4]); err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+2, err)
+		}
+		if r.Telegraph, err = strconv.ParseBool(row[5]); err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+2, err)
+		}
+		if r.Paused, err = strconv.ParseBool(row[6]); err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+2, err)
+		}
+		r.Delivery = row[7]
+		if row[8] != "" {
+			r.Filters = strings.Split(row[8], "\n")
+		}
+		items = append(items, r.importItem())
+	}
+	return items, nil
+}
+
+// applySettings restores the settings of an export on sub, they are all checked before any is changed
+func applySettings(sub *model.Subscribe, s *subSettings) error {
+	delivery, ok := parseDeliveryMode(s.Delivery)
+	if !ok && s.Delivery != "" {
+		return fmt.Errorf("unknown delivery mode %q", s.Delivery)
+	}
+	var filters []FilterRule
+	for _, spec := range s.Filters {
+		rule, err := parseFilterRule(spec)
+		if err != nil {
+			return fmt.Errorf("filter %q: %v", spec, err)
+		}
+		filters = append(filters, rule)
+	}
+
+	if s.Interval > 0 && s.Interval != sub.Interval {
+		if err := sub.SetInterval(s.Interval); err != nil {
+			return err
+		}
+	}
+	toggled := false
+	if s.Notification != (sub.EnableNotification == 1) {
+		if err := sub.ToggleNotification(); err != nil {
+			return err
+		}
+		toggled = true
+	}
+	if s.Telegraph != (sub.EnableTelegraph == 1) {
+		if err := sub.ToggleTelegraph(); err != nil {
+			return err
+		}
+		toggled = true
+	}
+	if toggled {
+		// the toggles only change sub, like in toggleCtrlButtons
+		sub.Save()
+	}
+
+	return subOptions.Update(func(opt *SubOption) {
+		opt.Paused = s.Paused
+		opt.Delivery = delivery
+		opt.Filters = filters
+	}, sub.ID)
+}
@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/indes/flowerss-bot/model"
+)
+
+// TestMain keeps the stores the tests write out of the data directory
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "flowerss-bot")
+	if err != nil {
+		panic(err)
+	}
+	SetPersister(NewFilePersister(dir))
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	exported := subSource{
+		sub: model.Subscribe{
+			ID: 1, Tag: "#go #news", Interval: 10, EnableNotification: 1, EnableTelegraph: 0,
+		},
+		source: model.Source{Title: "Go", Link: "https://go.dev/blog/feed.atom"},
+	}
+	filters := []FilterRule{{Include: true, Pattern: "release"}, {TitleOnly: true, Regex: true, Pattern: `^\[ad\]`}}
+	err := subOptions.Update(func(opt *SubOption) {
+		opt.Paused = true
+		opt.Delivery = deliveryDaily
+		opt.Filters = filters
+	}, exported.sub.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		export func([]subSource) (string, error)
+		subID  uint
+	}{
+		{"json", toExportJSON, 2},
+		{"csv", toExportCSV, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.export([]subSource{exported})
+			if err != nil {
+				t.Fatalf("export error = %v", err)
+			}
+			items, _, err := parseImportFile([]byte(data))
+			if err != nil {
+				t.Fatalf("parseImportFile() error = %v", err)
+			}
+			if len(items) != 1 || items[0].Settings == nil {
+				t.Fatalf("parseImportFile() = %+v, want one item with settings", items)
+			}
+			item := items[0]
+			if item.URL != exported.source.Link || item.Title != exported.source.Title || !reflect.DeepEqual(item.Tags, []string{"go", "news"}) {
+				t.Errorf("imported %+v", item)
+			}
+
+			sub := model.Subscribe{ID: tt.subID, Interval: 5, EnableNotification: 0, EnableTelegraph: 1}
+			if err := applySettings(&sub, item.Settings); err != nil {
+				t.Fatalf("applySettings() error = %v", err)
+			}
+			if sub.Interval != 10 || sub.EnableNotification != 1 || sub.EnableTelegraph != 0 {
+				t.Errorf("applySettings() sub = %+v", sub)
+			}
+			opt := subOptions.Get(tt.subID)
+			if !opt.Paused || opt.Delivery != deliveryDaily || !reflect.DeepEqual(opt.Filters, filters) {
+				t.Errorf("applySettings() options = %+v", opt)
+			}
+		})
+	}
+}
+
+func TestApplySettingsInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings subSettings
+	}{
+		{"delivery", subSettings{Interval: 30, Notification: true, Delivery: "monthly"}},
+		{"filter", subSettings{Interval: 30, Notification: true, Filters: []string{"+ok", "no sign"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := model.Subscribe{ID: 4, Interval: 5}
+			if err := applySettings(&sub, &tt.settings); err == nil {
+				t.Fatal("applySettings() error = nil")
+			}
+			if sub.Interval != 5 || sub.EnableNotification != 0 {
+				t.Errorf("applySettings() changed sub to %+v", sub)
+			}
+			if opt := subOptions.Get(4); !reflect.DeepEqual(opt, SubOption{}) {
+				t.Errorf("applySettings() changed the options to %+v", opt)
+			}
+		})
+	}
+}
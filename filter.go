@@ -0,0 +1,323 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/indes/flowerss-bot/model"
+	"go.uber.org/zap"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const maxFilterRules = 20
+
+// FilterRule keeps (Include) or drops the items whose title, or title and content, match Pattern
+type FilterRule struct {
+	Include   bool   `json:"include"`
+	Regex     bool   `json:"regex"`
+	TitleOnly bool   `json:"title_only"`
+	Pattern   string `json:"pattern"`
+	Hits      uint64 `json:"hits"`
+}
+
+var regexCache sync.Map
+
+func compileFilter(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexCache.Store(pattern, re)
+	return re, nil
+}
+
+// parseFilterRule parses "+word", "-/regex/", "+title:word" or "-title:/regex/"
+func parseFilterRule(spec string) (FilterRule, error) {
+	var rule FilterRule
+	spec = strings.TrimSpace(spec)
+	switch {
+	case strings.HasPrefix(spec, "+"):
+		rule.Include = true
+	case strings.HasPrefix(spec, "-"):
+	default:
+		return rule, fmt.Errorf("filter must start with + or -")
+	}
+	spec = spec[1:]
+
+	if strings.HasPrefix(spec, "title:") {
+		rule.TitleOnly = true
+		spec = strings.TrimPrefix(spec, "title:")
+	}
+
+	if len(spec) > 2 && strings.HasPrefix(spec, "/") && strings.HasSuffix(spec, "/") {
+		rule.Regex = true
+		spec = spec[1 : len(spec)-1]
+		if _, err := compileFilter(spec); err != nil {
+			return rule, err
+		}
+	}
+
+	if spec == "" {
+		return rule, fmt.Errorf("empty filter")
+	}
+	rule.Pattern = spec
+	return rule, nil
+}
+
+func (r FilterRule) String() string {
+	s := "-"
+	if r.Include {
+		s = "+"
+	}
+	if r.TitleOnly {
+		s += "title:"
+	}
+	if r.Regex {
+		return s + "/" + r.Pattern + "/"
+	}
+	return s + r.Pattern
+}
+
+func (r FilterRule) match(content *model.Content) bool {
+	text := content.Title
+	if !r.TitleOnly {
+		text += "\n" + content.Description
+	}
+
+	if r.Regex {
+		re, err := compileFilter(r.Pattern)
+		if err != nil {
+			return false
+		}
+		return re.MatchString(text)
+	}
+	return strings.Contains(strings.ToLower(text), strings.ToLower(r.Pattern))
+}
+
+// blockingFilters returns the rules that suppress content, nil when content passes every rule
+func blockingFilters(rules []FilterRule, content *model.Content) []int {
+	var includes []int
+	includeMatched := false
+	for i, rule := range rules {
+		matched := rule.match(content)
+		if rule.Include {
+			includes = append(includes, i)
+			includeMatched = includeMatched || matched
+			continue
+		}
+		if matched {
+			return []int{i}
+		}
+	}
+
+	if len(includes) > 0 && !includeMatched {
+		return includes
+	}
+	return nil
+}
+
+// filterNews reports whether sub's filters let content through and counts the suppressed item
+func filterNews(sub *model.Subscribe, opt SubOption, content *model.Content) bool {
+	blocked := blockingFilters(opt.Filters, content)
+	if blocked == nil {
+		return true
+	}
+
+	subOptions.UpdateLater(func(opt *SubOption) {
+		// copy before counting, readers may still hold the old slice
+		filters := append([]FilterRule(nil), opt.Filters...)
+		for _, i := range blocked {
+			if i < len(filters) {
+				filters[i].Hits++
+			}
+		}
+		opt.Filters = filters
+		opt.Filtered++
+	}, sub.ID)
+	return false
+}
+
+// listFilteredNote is appended to a /list entry when the filters of subID suppressed items, with the
+// items suppressed by each rule, numbered as in /setfilter
+func listFilteredNote(lang string, subID uint) string {
+	opt := subOptions.Get(subID)
+	if opt.Filtered == 0 {
+		return ""
+	}
+	var rules []string
+	for i, rule := range opt.Filters {
+		if rule.Hits > 0 {
+			rules = append(rules, fmt.Sprintf("#%d ×%d", i+1, rule.Hits))
+		}
+	}
+	if len(rules) == 0 {
+		// the rules that suppressed them were removed
+		return tr(lang, "list_filtered", opt.Filtered)
+	}
+	return tr(lang, "list_filtered_by", opt.Filtered, strings.Join(rules, ", "))
+}
+
+func formatFilters(rules []FilterRule) string {
+	parts := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		parts = append(parts, rule.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+func setFilterCmdCtr(m *tb.Message) {
+	lang := msgLang(m)
+	args := commandArgs(m)
+	if len(args) < 1 {
+		_, _ = B.Send(m.Chat, tr(lang, "setfilter_usage"))
+		return
+	}
+
+	subID, err := strconv.Atoi(args[0])
+	if err != nil {
+		_, _ = B.Send(m.Chat, tr(lang, "invalid_sub_id"))
+		return
+	}
+	sub, err := model.GetSubscribeByID(subID)
+	if err != nil || sub == nil {
+		_, _ = B.Send(m.Chat, tr(lang, "invalid_sub_id"))
+		return
+	}
+
+	if !authOwner(m, lang, sub.UserID) {
+		return
+	}
+
+	opt := subOptions.Get(sub.ID)
+	switch {
+	case len(args) == 1:
+		_, _ = B.Send(m.Chat, filterListMessage(lang, sub, opt), &tb.SendOptions{ParseMode: tb.ModeHTML})
+		return
+	case args[1] == "clear":
+		err = subOptions.Update(func(opt *SubOption) { opt.Filters = nil }, sub.ID)
+	case args[1] == "del" && len(args) == 3:
+		index, convErr := strconv.Atoi(args[2])
+		if convErr != nil || index < 1 || index > len(opt.Filters) {
+			_, _ = B.Send(m.Chat, tr(lang, "setfilter_usage"))
+			return
+		}
+		err = subOptions.Update(func(opt *SubOption) { opt.Filters = removeFilter(opt.Filters, index-1) }, sub.ID)
+	default:
+		if len(opt.Filters) >= maxFilterRules {
+			_, _ = B.Send(m.Chat, tr(lang, "setfilter_too_many", maxFilterRules))
+			return
+		}
+		// the pattern may contain spaces, take everything after the sub id
+		spec := commandRest(m, 1)
+		rule, parseErr := parseFilterRule(spec)
+		if parseErr != nil {
+			_, _ = B.Send(m.Chat, tr(lang, "setfilter_invalid", parseErr.Error()))
+			return
+		}
+		err = subOptions.Update(func(opt *SubOption) { opt.Filters = append(opt.Filters, rule) }, sub.ID)
+	}
+
+	if err != nil {
+		zap.S().Errorf("save filters of sub %d failed, err:%+v", sub.ID, err)
+		_, _ = B.Send(m.Chat, tr(lang, "setfilter_failed"))
+		return
+	}
+	_, _ = B.Send(m.Chat, filterListMessage(lang, sub, subOptions.Get(sub.ID)), &tb.SendOptions{ParseMode: tb.ModeHTML})
+}
+
+// removeFilter returns a new slice so copies handed out by subOptions.Get stay untouched
+func removeFilter(rules []FilterRule, i int) []FilterRule {
+	result := make([]FilterRule, 0, len(rules)-1)
+	result = append(result, rules[:i]...)
+	return append(result, rules[i+1:]...)
+}
+
+func filterListMessage(lang string, sub *model.Subscribe, opt SubOption) string {
+	if len(opt.Filters) == 0 {
+		return tr(lang, "filter_list_empty", sub.ID)
+	}
+	msg := tr(lang, "filter_list_title", sub.ID, opt.Filtered)
+	for i, rule := range opt.Filters {
+		msg += fmt.Sprintf("\n[%d] <code>%s</code> (%d)", i+1, html.EscapeString(rule.String()), rule.Hits)
+	}
+	return msg
+}
+
+func genFilterBtn(lang string, chatID int64, sub *model.Subscribe, opt SubOption) [][]tb.InlineButton {
+	var keys [][]tb.InlineButton
+	for i, rule := range opt.Filters {
+		keys = append(keys, []tb.InlineButton{
+			tb.InlineButton{
+				Unique: "del_sub_filter_btn",
+				Text:   tr(lang, "btn_filter_delete", rule.String()),
+				Data: newCallback(chatID, callbackPayload{
+					Owner: sub.UserID, SubID: sub.ID, SourceID: sub.SourceID, Index: i, Arg: rule.String(),
+				}),
+			},
+		})
+	}
+	keys = append(keys, []tb.InlineButton{
+		tb.InlineButton{
+			Unique: "set_feed_item_btn",
+			Text:   tr(lang, "btn_back"),
+			Data:   newCallback(chatID, callbackPayload{Owner: sub.UserID, SourceID: sub.SourceID}),
+		},
+	})
+	return keys
+}
+
+func setSubFilterBtnCtr(c *tb.Callback) {
+	payload, ok := loadCallback(c)
+	if !ok || !callbackAuth(c, payload.Owner) {
+		return
+	}
+	showSubFilters(c, payload)
+}
+
+func delSubFilterBtnCtr(c *tb.Callback) {
+	payload, ok := loadCallback(c)
+	if !ok || !callbackAuth(c, payload.Owner) {
+		return
+	}
+
+	index := payload.Index
+	err := subOptions.Update(func(opt *SubOption) {
+		// the rules may have changed since the buttons were sent
+		if index >= 0 && index < len(opt.Filters) && opt.Filters[index].String() == payload.Arg {
+			opt.Filters = removeFilter(opt.Filters, index)
+		}
+	}, payload.SubID)
+	if err != nil {
+		_ = B.Respond(c, &tb.CallbackResponse{Text: tr(cbLang(c), "error")})
+		return
+	}
+	showSubFilters(c, payload)
+}
+
+func showSubFilters(c *tb.Callback, payload callbackPayload) {
+	lang := cbLang(c)
+	sub, err := model.GetSubscribeByID(int(payload.SubID))
+	if err != nil || sub == nil || sub.UserID != payload.Owner {
+		_ = B.Respond(c, &tb.CallbackResponse{Text: tr(lang, "error")})
+		return
+	}
+
+	opt := subOptions.Get(sub.ID)
+	_, _ = B.Edit(
+		c.Message,
+		filterListMessage(lang, sub, opt)+"\n\n"+tr(lang, "setfilter_hint", sub.ID, sub.ID),
+		&tb.SendOptions{
+			ParseMode: tb.ModeHTML,
+		}, &tb.ReplyMarkup{
+			InlineKeyboard: genFilterBtn(lang, c.Message.Chat.ID, sub, opt),
+		},
+	)
+}
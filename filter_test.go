@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/indes/flowerss-bot/model"
+)
+
+func TestParseFilterRule(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    FilterRule
+		wantErr bool
+	}{
+		{spec: "+golang", want: FilterRule{Include: true, Pattern: "golang"}},
+		{spec: "-ads and promos", want: FilterRule{Pattern: "ads and promos"}},
+		{spec: "+title:release", want: FilterRule{Include: true, TitleOnly: true, Pattern: "release"}},
+		{spec: "-title:/^\\[ad\\]/", want: FilterRule{TitleOnly: true, Regex: true, Pattern: "^\\[ad\\]"}},
+		{spec: "-//", want: FilterRule{Pattern: "//"}},
+		{spec: "golang", wantErr: true},
+		{spec: "+", wantErr: true},
+		{spec: "-title:", wantErr: true},
+		{spec: "+/(unclosed/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseFilterRule(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseFilterRule(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseFilterRule(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+		if !tt.wantErr && got.String() != tt.spec {
+			t.Errorf("parseFilterRule(%q).String() = %q", tt.spec, got.String())
+		}
+	}
+}
+
+func TestBlockingFilters(t *testing.T) {
+	rules := []FilterRule{
+		{Include: true, Pattern: "go"},
+		{Include: true, TitleOnly: true, Regex: true, Pattern: `^Rust \d`},
+		{Pattern: "sponsored"},
+	}
+	tests := []struct {
+		name    string
+		rules   []FilterRule
+		content model.Content
+		want    []int
+	}{
+		{"no rules", nil, model.Content{Title: "anything"}, nil},
+		{"include in content", rules, model.Content{Title: "News", Description: "about Go"}, nil},
+		{"include regex in title", rules, model.Content{Title: "Rust 2 released"}, nil},
+		{"title only rule skips content", rules, model.Content{Title: "News", Description: "Rust 2"}, []int{0, 1}},
+		{"exclude wins", rules, model.Content{Title: "Go tips", Description: "Sponsored post"}, []int{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := blockingFilters(tt.rules, &tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("blockingFilters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
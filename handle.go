@@ -0,0 +1,135 @@
+package bot
+
+import (
+	"go.uber.org/zap"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// Start registers the handlers, starts the background jobs and polls the updates
+func Start() {
+	zap.S().Info("bot start")
+	makeHandle()
+	startJobs()
+	B.Start()
+}
+
+// makeHandle registers the handlers of all the commands and buttons
+func makeHandle() {
+	setFeedItemBtn := tb.InlineButton{
+		Unique: "set_feed_item_btn",
+	}
+	setToggleNoticeBtn := tb.InlineButton{
+		Unique: "set_toggle_notice_btn",
+	}
+	setToggleTelegraphBtn := tb.InlineButton{
+		Unique: "set_toggle_telegraph_btn",
+	}
+	setToggleUpdateBtn := tb.InlineButton{
+		Unique: "set_toggle_update_btn",
+	}
+	setSubTagBtn := tb.InlineButton{
+		Unique: "set_set_sub_tag_btn",
+	}
+	unsubFeedItemBtn := tb.InlineButton{
+		Unique: "unsub_feed_item_btn",
+	}
+	unsubAllConfirmBtn := tb.InlineButton{
+		Unique: "unsub_all_confirm_btn",
+	}
+	unsubAllCancelBtn := tb.InlineButton{
+		Unique: "unsub_all_cancel_btn",
+	}
+	setSubFilterBtn := tb.InlineButton{
+		Unique: "set_sub_filter_btn",
+	}
+	delSubFilterBtn := tb.InlineButton{
+		Unique: "del_sub_filter_btn",
+	}
+	setToggleDeliveryBtn := tb.InlineButton{
+		Unique: "set_toggle_delivery_btn",
+	}
+	pageBtn := tb.InlineButton{
+		Unique: "page_btn",
+	}
+	channelViewBtn := tb.InlineButton{
+		Unique: "channel_view_btn",
+	}
+	importCancelBtn := tb.InlineButton{
+		Unique: "import_cancel_btn",
+	}
+	importConfirmBtn := tb.InlineButton{
+		Unique: "import_confirm_btn",
+	}
+	importDiscardBtn := tb.InlineButton{
+		Unique: "import_discard_btn",
+	}
+	subDiscoverBtn := tb.InlineButton{
+		Unique: "sub_discover_btn",
+	}
+	previewSubBtn := tb.InlineButton{
+		Unique: "preview_sub_btn",
+	}
+	subLastBtn := tb.InlineButton{
+		Unique: "sub_last_btn",
+	}
+	delWatchBtn := tb.InlineButton{
+		Unique: "del_watch_btn",
+	}
+
+	B.Handle(&setFeedItemBtn, setFeedItemBtnCtr)
+	B.Handle(&setToggleNoticeBtn, setToggleNoticeBtnCtr)
+	B.Handle(&setToggleTelegraphBtn, setToggleTelegraphBtnCtr)
+	B.Handle(&setToggleUpdateBtn, setToggleUpdateBtnCtr)
+	B.Handle(&setSubTagBtn, setSubTagBtnCtr)
+	B.Handle(&unsubFeedItemBtn, unsubFeedItemBtnCtr)
+	B.Handle(&unsubAllConfirmBtn, unsubAllConfirmBtnCtr)
+	B.Handle(&unsubAllCancelBtn, unsubAllCancelBtnCtr)
+	B.Handle(&setSubFilterBtn, setSubFilterBtnCtr)
+	B.Handle(&delSubFilterBtn, delSubFilterBtnCtr)
+	B.Handle(&setToggleDeliveryBtn, setToggleDeliveryBtnCtr)
+	B.Handle(&pageBtn, pageBtnCtr)
+	B.Handle(&channelViewBtn, channelViewBtnCtr)
+	B.Handle(&importCancelBtn, importCancelBtnCtr)
+	B.Handle(&importConfirmBtn, importConfirmBtnCtr)
+	B.Handle(&importDiscardBtn, importDiscardBtnCtr)
+	B.Handle(&subDiscoverBtn, subDiscoverBtnCtr)
+	B.Handle(&previewSubBtn, previewSubBtnCtr)
+	B.Handle(&subLastBtn, subLastBtnCtr)
+	B.Handle(&delWatchBtn, delWatchBtnCtr)
+
+	B.Handle("/start", startCmdCtr)
+	B.Handle("/export", exportCmdCtr)
+	B.Handle("/sub", subCmdCtr)
+	B.Handle("/list", listCmdCtr)
+	B.Handle("/set", setCmdCtr)
+	B.Handle("/unsub", unsubCmdCtr)
+	B.Handle("/unsuball", unsubAllCmdCtr)
+	B.Handle("/ping", pingCmdCtr)
+	B.Handle("/help", helpCmdCtr)
+	B.Handle("/import", importCmdCtr)
+	B.Handle("/setfeedtag", setFeedTagCmdCtr)
+	B.Handle("/setinterval", setIntervalCmdCtr)
+	B.Handle("/check", checkCmdCtr)
+	B.Handle("/activeall", activeAllCmdCtr)
+	B.Handle("/pauseall", pauseAllCmdCtr)
+	B.Handle("/version", versionCmdCtr)
+	B.Handle("/lang", langCmdCtr)
+	B.Handle("/cancel", cancelCmdCtr)
+	B.Handle("/setfilter", setFilterCmdCtr)
+	B.Handle("/digest", digestCmdCtr)
+	B.Handle("/use", useCmdCtr)
+	B.Handle("/channels", channelsCmdCtr)
+	B.Handle("/importstatus", importStatusCmdCtr)
+	B.Handle("/backup", backupCmdCtr)
+	B.Handle("/syncopml", syncOPMLCmdCtr)
+	B.Handle("/preview", previewCmdCtr)
+	B.Handle("/backfill", backfillCmdCtr)
+	B.Handle("/last", lastCmdCtr)
+	B.Handle("/search", searchCmdCtr)
+	B.Handle("/watch", watchCmdCtr)
+	B.Handle("/watches", watchesCmdCtr)
+
+	B.Handle(tb.OnText, textCtr)
+	B.Handle(tb.OnDocument, docCtr)
+}
@@ -0,0 +1,221 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/indes/flowerss-bot/config"
+	"github.com/indes/flowerss-bot/model"
+	"github.com/indes/flowerss-bot/tgraph"
+	"go.uber.org/zap"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	itemHistoryKey = "item_history"
+	// maxHistoryItems is the number of items kept per source
+	maxHistoryItems = 50
+	// defaultLastItems is the number of items /last shows without a count
+	defaultLastItems = 10
+)
+
+// historyItem is an item of a source as it was fetched
+type historyItem struct {
+	HashID       string    `json:"hash_id"`
+	Title        string    `json:"title"`
+	Link         string    `json:"link"`
+	TelegraphURL string    `json:"telegraph_url,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+// itemHistoryStore keeps the latest items of every source, newest last
+type itemHistoryStore struct {
+	once  sync.Once
+	mu    sync.Mutex
+	items map[uint][]historyItem
+	// dirty is set by Record, flush saves the items
+	dirty bool
+}
+
+var itemHistory = &itemHistoryStore{}
+
+func (s *itemHistoryStore) load() {
+	s.once.Do(func() {
+		s.items = make(map[uint][]historyItem)
+		if err := persister.Load(itemHistoryKey, &s.items); err != nil {
+			zap.S().Errorf("load item history failed, err:%+v", err)
+		}
+	})
+}
+
+// Record adds content to the history of source, contents already recorded are skipped.
+// It is saved by the next flush.
+func (s *itemHistoryStore) Record(source *model.Source, content *model.Content) {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := s.items[source.ID]
+	for i := len(items) - 1; i >= 0; i-- {
+		// sources may send a content again
+		if items[i].HashID == content.HashID {
+			return
+		}
+	}
+	items = append(items, historyItem{
+		HashID:       content.HashID,
+		Title:        content.Title,
+		Link:         content.RawLink,
+		TelegraphURL: content.TelegraphUrl,
+		FetchedAt:    time.Now(),
+	})
+	if len(items) > maxHistoryItems {
+		items = items[len(items)-maxHistoryItems:]
+	}
+	s.items[source.ID] = items
+	s.dirty = true
+}
+
+func (s *itemHistoryStore) flush() {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return
+	}
+	if err := persister.Save(itemHistoryKey, s.items); err != nil {
+		zap.S().Errorf("save item history failed, err:%+v", err)
+		return
+	}
+	s.dirty = false
+}
+
+// Last returns the n latest items of sourceID, newest first
+func (s *itemHistoryStore) Last(sourceID uint, n int) []historyItem {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := s.items[sourceID]
+	var last []historyItem
+	for i := len(items) - 1; i >= 0 && len(last) < n; i-- {
+		last = append(last, items[i])
+	}
+	return last
+}
+
+// storedItems returns the n last contents stored by source, for the sources not updated since the history is kept
+func storedItems(source *model.Source, n int) []historyItem {
+	var items []historyItem
+	for i := len(source.Content) - 1; i >= 0 && len(items) < n; i-- {
+		content := source.Content[i]
+		items = append(items, historyItem{
+			HashID:       content.HashID,
+			Title:        content.Title,
+			Link:         content.RawLink,
+			TelegraphURL: content.TelegraphUrl,
+		})
+	}
+	return items
+}
+
+func renderLastItems(lang string, source *model.Source, items []historyItem) string {
+	msg := tr(lang, "last_title", html.EscapeString(source.Title), len(items))
+	for i, item := range items {
+		msg += fmt.Sprintf("\n%d. <a href=\"%s\">%s</a>", i+1, html.EscapeString(item.Link), html.EscapeString(item.Title))
+		if !item.FetchedAt.IsZero() {
+			msg += " " + item.FetchedAt.Format("01-02 15:04")
+		}
+		if item.TelegraphURL != "" {
+			msg += fmt.Sprintf(" | <a href=\"%s\">Telegraph</a>", html.EscapeString(item.TelegraphURL))
+		}
+	}
+	return msg
+}
+
+// sendLastItems sends the n latest items of the source of sub to chat, through Telegraph when too long
+func sendLastItems(chat *tb.Chat, lang string, sub *model.Subscribe, n int) {
+	source, err := model.GetSourceById(sub.SourceID)
+	if err != nil {
+		_, _ = B.Send(chat, tr(lang, "error"))
+		return
+	}
+	items := itemHistory.Last(source.ID, n)
+	if len(items) == 0 {
+		items = storedItems(source, n)
+	}
+	if len(items) == 0 {
+		_, _ = B.Send(chat, tr(lang, "last_empty"))
+		return
+	}
+
+	msg := renderLastItems(lang, source, items)
+	if len(msg) > digestMaxLength && config.EnableTelegraph {
+		title := tr(lang, "last_page_title", source.Title)
+		url, err := tgraph.PublishHtml(source.Title, title, source.Link, strings.Replace(msg, "\n", "<br>", -1))
+		if err == nil {
+			msg = fmt.Sprintf("<a href=\"%s\">%s</a>", url, html.EscapeString(title))
+		} else {
+			zap.S().Warnf("publish last items of %d to telegraph failed, err:%+v", source.ID, err)
+		}
+	}
+
+	for _, part := range splitMessage(msg, digestMaxLength) {
+		_, _ = B.Send(chat, part, &tb.SendOptions{
+			DisableWebPagePreview: true,
+			ParseMode:             tb.ModeHTML,
+		})
+	}
+}
+
+func lastCmdCtr(m *tb.Message) {
+	lang := msgLang(m)
+	args := commandArgs(m)
+	if len(args) == 0 {
+		_, _ = B.Send(m.Chat, tr(lang, "last_usage", maxHistoryItems))
+		return
+	}
+	subID, err := strconv.Atoi(args[0])
+	if err != nil {
+		_, _ = B.Send(m.Chat, tr(lang, "last_usage", maxHistoryItems))
+		return
+	}
+	n := defaultLastItems
+	if len(args) > 1 {
+		if n, err = strconv.Atoi(args[1]); err != nil || n <= 0 || n > maxHistoryItems {
+			_, _ = B.Send(m.Chat, tr(lang, "last_usage", maxHistoryItems))
+			return
+		}
+	}
+
+	sub, err := model.GetSubscribeByID(subID)
+	if err != nil || sub == nil {
+		_, _ = B.Send(m.Chat, tr(lang, "invalid_sub_id"))
+		return
+	}
+	if !authOwner(m, lang, sub.UserID) {
+		return
+	}
+	sendLastItems(m.Chat, lang, sub, n)
+}
+
+func subLastBtnCtr(c *tb.Callback) {
+	payload, ok := loadCallback(c)
+	if !ok || !callbackAuth(c, payload.Owner) {
+		return
+	}
+	lang := cbLang(c)
+	sub, err := model.GetSubscribeByID(int(payload.SubID))
+	if err != nil || sub == nil || sub.UserID != payload.Owner {
+		_ = B.Respond(c, &tb.CallbackResponse{Text: tr(lang, "error")})
+		return
+	}
+	_ = B.Respond(c)
+	sendLastItems(c.Message.Chat, lang, sub, defaultLastItems)
+}
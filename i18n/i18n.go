@@ -0,0 +1,132 @@
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Catalog holds the translated messages of every loaded language.
+// Lookups fall back to the default language when a key is missing.
+type Catalog struct {
+	mu       sync.RWMutex
+	fallback string
+	messages map[string]map[string]string
+}
+
+// New returns an empty catalog whose fallback language is fallback
+func New(fallback string) *Catalog {
+	return &Catalog{
+		fallback: Normalize(fallback),
+		messages: make(map[string]map[string]string),
+	}
+}
+
+// Normalize turns a Telegram language code (e.g. "pt-br") into a catalog language ("pt")
+func Normalize(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if i := strings.IndexAny(code, "-_"); i > 0 {
+		code = code[:i]
+	}
+	return code
+}
+
+// Add merges msgs into lang, overriding keys that already exist
+func (c *Catalog) Add(lang string, msgs map[string]string) {
+	lang = Normalize(lang)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.messages[lang] == nil {
+		c.messages[lang] = make(map[string]string, len(msgs))
+	}
+	for k, v := range msgs {
+		c.messages[lang][k] = v
+	}
+}
+
+// LoadFile loads a JSON object of key/message pairs, the file name is the language, e.g. en.json
+func (c *Catalog) LoadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	msgs := make(map[string]string)
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	lang := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	c.Add(lang, msgs)
+	return nil
+}
+
+// LoadDir loads every *.json file in dir, a missing dir is not an error
+func (c *Catalog) LoadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		if _, err := os.Stat(dir); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	for _, f := range files {
+		if err := c.LoadFile(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Supports reports whether lang has been loaded
+func (c *Catalog) Supports(lang string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.messages[Normalize(lang)]
+	return ok
+}
+
+// Languages returns the loaded languages in alphabetical order
+func (c *Catalog) Languages() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	langs := make([]string, 0, len(c.messages))
+	for lang := range c.messages {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Get returns the message of key in lang, falling back to the default language and then to key itself
+func (c *Catalog) Get(lang, key string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if msg, ok := c.messages[Normalize(lang)][key]; ok {
+		return msg
+	}
+	if msg, ok := c.messages[c.fallback][key]; ok {
+		return msg
+	}
+	return key
+}
+
+// Sprintf formats the message of key in lang with args
+func (c *Catalog) Sprintf(lang, key string, args ...interface{}) string {
+	if len(args) == 0 {
+		return c.Get(lang, key)
+	}
+	return fmt.Sprintf(c.Get(lang, key), args...)
+}
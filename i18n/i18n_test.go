@@ -0,0 +1,114 @@
+package i18n
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"es", "es"},
+		{"es-MX", "es"},
+		{"pt_BR", "pt"},
+		{" EN ", "en"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.code); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestCatalogGet(t *testing.T) {
+	c := New("es")
+	c.Add("es", map[string]string{"hello": "hola", "bye": "adiós"})
+	c.Add("en-US", map[string]string{"hello": "hello"})
+
+	tests := []struct {
+		lang string
+		key  string
+		want string
+	}{
+		{"en", "hello", "hello"},
+		{"en-GB", "hello", "hello"},
+		{"en", "bye", "adiós"},
+		{"es-MX", "hello", "hola"},
+		{"fr", "hello", "hola"},
+		{"", "bye", "adiós"},
+		{"en", "missing", "missing"},
+	}
+
+	for _, tt := range tests {
+		if got := c.Get(tt.lang, tt.key); got != tt.want {
+			t.Errorf("Get(%q, %q) = %q, want %q", tt.lang, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestCatalogLoadDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i18n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"es.json":   `{"hello": "¡hola!", "new": "nuevo %d"}`,
+		"pt.json":   `{"hello": "olá"}`,
+		"notes.txt": `ignored`,
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := New("es")
+	c.Add("es", map[string]string{"hello": "hola", "bye": "adiós"})
+	if err := c.LoadDir(dir); err != nil {
+		t.Fatalf("LoadDir() error = %v", err)
+	}
+
+	if got := c.Get("es", "hello"); got != "¡hola!" {
+		t.Errorf("LoadDir() did not override a built-in key, got %q", got)
+	}
+	if got := c.Get("es", "bye"); got != "adiós" {
+		t.Errorf("LoadDir() dropped a built-in key, got %q", got)
+	}
+	if got := c.Sprintf("pt", "new", 2); got != "nuevo 2" {
+		t.Errorf("Sprintf() = %q, want the fallback", got)
+	}
+	if got, want := c.Languages(), []string{"es", "pt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Languages() = %q, want %q", got, want)
+	}
+	if !c.Supports("pt-BR") || c.Supports("fr") {
+		t.Error("Supports() does not match the loaded languages")
+	}
+}
+
+func TestCatalogLoadDirErrors(t *testing.T) {
+	c := New("es")
+	if err := c.LoadDir(filepath.Join(os.TempDir(), "i18n-missing-dir")); err != nil {
+		t.Errorf("LoadDir() of a missing dir error = %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "i18n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "en.json"), []byte(`{"hello": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.LoadDir(dir); err == nil {
+		t.Error("LoadDir() of a broken file error = nil")
+	}
+}
@@ -0,0 +1,678 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/indes/flowerss-bot/model"
+	"go.uber.org/zap"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	importJobKey = "import_jobs"
+	// importWorkers bounds the feeds fetched at the same time by all the imports
+	importWorkers = 8
+	// importProgressInterval is how often the status message of a running import is edited
+	importProgressInterval = 3 * time.Second
+	// importJobKeep is how long the result of a finished import stays available to /importstatus
+	importJobKeep = 24 * time.Hour
+	// importPreviewKeep is how long an import preview waits to be confirmed
+	importPreviewKeep = time.Hour
+	// importPreviewLines is the number of feeds listed per section of the preview
+	importPreviewLines = 20
+)
+
+type importState int
+
+const (
+	importPending importState = iota
+	importDone
+	importFailed
+)
+
+type importItem struct {
+	Title string      `json:"title,omitempty"`
+	URL   string      `json:"url"`
+	Tags  []string    `json:"tags,omitempty"`
+	State importState `json:"state"`
+	// Settings are restored on the subscription, they come from /export json and csv files
+	Settings *subSettings `json:"settings,omitempty"`
+	// Discover subscribes to the feed of the web page at URL, for the urls of /sub
+	Discover bool `json:"discover,omitempty"`
+}
+
+// importJob subscribes Owner to Items in the background, it is persisted so a restart resumes it
+type importJob struct {
+	ID         string           `json:"id"`
+	ChatID     int64            `json:"chat_id"`
+	Owner      int64            `json:"owner"`
+	Lang       string           `json:"lang"`
+	Status     tb.StoredMessage `json:"status"`
+	Items      []importItem     `json:"items"`
+	Preview    bool             `json:"preview,omitempty"`
+	Cancelled  bool             `json:"cancelled,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+}
+
+func (j *importJob) counts() (done, failed, pending int) {
+	for _, item := range j.Items {
+		switch item.State {
+		case importDone:
+			done++
+		case importFailed:
+			failed++
+		default:
+			pending++
+		}
+	}
+	return done, failed, pending
+}
+
+func (j *importJob) copy() *importJob {
+	c := *j
+	c.Items = append([]importItem(nil), j.Items...)
+	return &c
+}
+
+type importJobStore struct {
+	once sync.Once
+	mu   sync.Mutex
+	jobs map[string]*importJob
+}
+
+var importJobs = &importJobStore{}
+
+// importSlots is the worker pool shared by the running imports
+var importSlots = make(chan struct{}, importWorkers)
+
+func (s *importJobStore) load() {
+	s.once.Do(func() {
+		s.jobs = make(map[string]*importJob)
+		if err := persister.Load(importJobKey, &s.jobs); err != nil {
+			zap.S().Errorf("load import jobs failed, err:%+v", err)
+		}
+	})
+}
+
+// save must be called with s.mu held
+func (s *importJobStore) save() {
+	if err := persister.Save(importJobKey, s.jobs); err != nil {
+		zap.S().Errorf("save import jobs failed, err:%+v", err)
+	}
+}
+
+// Add stores job, it fails when the chat already has a running import. A preview of the chat waiting
+// for confirmation is replaced.
+func (s *importJobStore) Add(job *importJob) error {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for id, j := range s.jobs {
+		if j.Preview && (now.Sub(j.CreatedAt) > importPreviewKeep || j.ChatID == job.ChatID) {
+			delete(s.jobs, id)
+			continue
+		}
+		if j.FinishedAt == nil && j.ChatID == job.ChatID {
+			return fmt.Errorf("chat %d already has the running import %s", job.ChatID, id)
+		}
+		if j.FinishedAt != nil && now.Sub(*j.FinishedAt) > importJobKeep {
+			delete(s.jobs, id)
+		}
+	}
+
+	job.ID = strconv.FormatInt(now.UnixNano(), 36)
+	job.CreatedAt = now
+	s.jobs[job.ID] = job
+	s.save()
+	return nil
+}
+
+// Get returns a copy of the job id
+func (s *importJobStore) Get(id string) *importJob {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[id]; ok {
+		return job.copy()
+	}
+	return nil
+}
+
+// Latest returns a copy of the last confirmed import started from chatID
+func (s *importJobStore) Latest(chatID int64) *importJob {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var latest *importJob
+	for _, job := range s.jobs {
+		if job.ChatID == chatID && !job.Preview && (latest == nil || job.CreatedAt.After(latest.CreatedAt)) {
+			latest = job
+		}
+	}
+	if latest == nil {
+		return nil
+	}
+	return latest.copy()
+}
+
+// Unfinished returns the ids of the confirmed imports a restart interrupted
+func (s *importJobStore) Unfinished() []string {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ids []string
+	for id, job := range s.jobs {
+		if job.FinishedAt == nil && !job.Preview {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Update applies fn to the job id and saves it, fn must not keep job
+func (s *importJobStore) Update(id string, fn func(job *importJob)) {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job, ok := s.jobs[id]; ok {
+		fn(job)
+		s.save()
+	}
+}
+
+// Delete drops the job id
+func (s *importJobStore) Delete(id string) {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.jobs, id)
+	s.save()
+}
+
+// importPreview is the dry run of an import: what would be subscribed and what would be skipped
+type importPreview struct {
+	New        []importItem
+	Update     []importItem
+	Subscribed []importItem
+	Duplicate  []importItem
+	Invalid    []importItem
+}
+
+// normalizeFeedURL returns the key of a feed url, urls differing only by scheme, case of the host,
+// default port, fragment or trailing slash are the same feed
+func normalizeFeedURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid feed url %q", raw)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	key := host + strings.TrimSuffix(u.EscapedPath(), "/")
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
+	}
+	return key, nil
+}
+
+// previewImport sorts items into new, already subscribed by owner, duplicated and invalid feeds
+func previewImport(owner int64, items []importItem) (*importPreview, error) {
+	sources, err := model.GetSourcesByUserID(owner)
+	if err != nil {
+		return nil, err
+	}
+	subscribed := make(map[string]bool, len(sources))
+	for _, source := range sources {
+		if key, err := normalizeFeedURL(source.Link); err == nil {
+			subscribed[key] = true
+		}
+	}
+
+	preview := &importPreview{}
+	items, preview.Duplicate = mergeDuplicateItems(items)
+	for _, item := range items {
+		key, err := normalizeFeedURL(item.URL)
+		switch {
+		case err != nil:
+			preview.Invalid = append(preview.Invalid, item)
+		case subscribed[key] && item.Settings != nil:
+			// exports restore the settings of the subscriptions that already exist
+			preview.Update = append(preview.Update, item)
+		case subscribed[key]:
+			preview.Subscribed = append(preview.Subscribed, item)
+		default:
+			preview.New = append(preview.New, item)
+		}
+	}
+	return preview, nil
+}
+
+// mergeDuplicateItems keeps the first item of every url with the tags of all its items, a feed is in one
+// folder per tag in OPML files. The items adding no tag are returned as duplicates.
+func mergeDuplicateItems(items []importItem) ([]importItem, []importItem) {
+	var unique, duplicates []importItem
+	first := make(map[string]int, len(items))
+	for _, item := range items {
+		key, err := normalizeFeedURL(item.URL)
+		if err != nil {
+			unique = append(unique, item)
+			continue
+		}
+		i, seen := first[key]
+		if !seen {
+			first[key] = len(unique)
+			unique = append(unique, item)
+			continue
+		}
+		tags := mergeTags(unique[i].Tags, item.Tags)
+		if len(tags) == len(unique[i].Tags) {
+			duplicates = append(duplicates, item)
+			continue
+		}
+		unique[i].Tags = tags
+	}
+	return unique, duplicates
+}
+
+// mergeTags returns tags followed by the more tags it does not have, up to maxImportTags
+func mergeTags(tags []string, more []string) []string {
+	merged := append([]string(nil), tags...)
+	for _, tag := range more {
+		if len(merged) == maxImportTags {
+			break
+		}
+		if !containsString(merged, tag) {
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
+
+// sendImportPreview stores the new feeds of items as an import waiting for confirmation and shows the preview
+func sendImportPreview(m *tb.Message, lang string, owner int64, format string, items []importItem) {
+	preview, err := previewImport(owner, items)
+	if err != nil {
+		zap.S().Errorf("preview import for %d failed, err:%+v", owner, err)
+		_, _ = B.Send(m.Chat, tr(lang, "error"))
+		return
+	}
+
+	text := tr(lang, "import_preview", format, len(preview.New), len(preview.Update), len(preview.Subscribed), len(preview.Duplicate), len(preview.Invalid))
+	text += formatPreviewSection(lang, "import_preview_new", preview.New)
+	text += formatPreviewSection(lang, "import_preview_update", preview.Update)
+	text += formatPreviewSection(lang, "import_preview_subscribed", preview.Subscribed)
+	text += formatPreviewSection(lang, "import_preview_duplicate", preview.Duplicate)
+	text += formatPreviewSection(lang, "import_preview_invalid", preview.Invalid)
+	opt := &tb.SendOptions{DisableWebPagePreview: true, ParseMode: tb.ModeHTML}
+
+	items = append(preview.New, preview.Update...)
+	if len(items) == 0 {
+		_, _ = B.Send(m.Chat, text+"\n\n"+tr(lang, "import_preview_nothing"), opt)
+		return
+	}
+
+	job := &importJob{ChatID: m.Chat.ID, Owner: owner, Lang: lang, Items: items, Preview: true}
+	if err := importJobs.Add(job); err != nil {
+		zap.S().Warnf(err.Error())
+		_, _ = B.Send(m.Chat, tr(lang, "import_busy"))
+		return
+	}
+
+	_, _ = B.Send(m.Chat, text, opt, &tb.ReplyMarkup{
+		InlineKeyboard: [][]tb.InlineButton{
+			[]tb.InlineButton{
+				tb.InlineButton{
+					Unique: "import_confirm_btn",
+					Text:   tr(lang, "btn_import_confirm", len(items)),
+					Data:   newCallback(m.Chat.ID, callbackPayload{Owner: owner, Kind: "import", Arg: job.ID}),
+				},
+				tb.InlineButton{
+					Unique: "import_discard_btn",
+					Text:   tr(lang, "btn_cancel"),
+					Data:   newCallback(m.Chat.ID, callbackPayload{Owner: owner, Kind: "import", Arg: job.ID}),
+				},
+			},
+		},
+	})
+}
+
+func formatPreviewSection(lang string, title string, items []importItem) string {
+	if len(items) == 0 {
+		return ""
+	}
+	text := "\n\n" + tr(lang, title)
+	for i, item := range items {
+		if i == importPreviewLines {
+			text += "\n" + tr(lang, "import_preview_more", len(items)-i)
+			break
+		}
+		name := item.Title
+		if name == "" {
+			name = item.URL
+		}
+		if r := []rune(name); len(r) > 60 {
+			name = string(r[:60]) + "…"
+		}
+		text += fmt.Sprintf("\n[%d] %s", i+1, html.EscapeString(name))
+	}
+	return text
+}
+
+// previewJob returns the import waiting for confirmation behind the pressed button
+func previewJob(c *tb.Callback) *importJob {
+	payload, ok := loadCallback(c)
+	if !ok || payload.Kind != "import" || !callbackAuth(c, payload.Owner) {
+		return nil
+	}
+	job := importJobs.Get(payload.Arg)
+	if job == nil || !job.Preview {
+		_ = B.Respond(c, &tb.CallbackResponse{Text: tr(cbLang(c), "callback_expired"), ShowAlert: true})
+		return nil
+	}
+	return job
+}
+
+func importConfirmBtnCtr(c *tb.Callback) {
+	job := previewJob(c)
+	if job == nil {
+		return
+	}
+	_ = B.Respond(c)
+
+	importJobs.Update(job.ID, func(job *importJob) {
+		job.Preview = false
+		job.CreatedAt = time.Now()
+		job.Status = tb.StoredMessage{MessageID: strconv.Itoa(c.Message.ID), ChatID: c.Message.Chat.ID}
+	})
+	job = importJobs.Get(job.ID)
+	_, _ = B.Edit(c.Message, importProgress(job), &tb.ReplyMarkup{InlineKeyboard: importCancelBtn(job)})
+	go runImport(job.ID)
+}
+
+func importDiscardBtnCtr(c *tb.Callback) {
+	job := previewJob(c)
+	if job == nil {
+		return
+	}
+	_ = B.Respond(c)
+
+	importJobs.Delete(job.ID)
+	_, _ = B.Edit(c.Message, tr(job.Lang, "operation_cancelled"))
+}
+
+// startImport runs items as an import of owner without preview, the progress is shown in the chat of m
+func startImport(m *tb.Message, lang string, owner int64, items []importItem) {
+	job := &importJob{ChatID: m.Chat.ID, Owner: owner, Lang: lang, Items: items}
+	if err := importJobs.Add(job); err != nil {
+		zap.S().Warnf(err.Error())
+		_, _ = B.Send(m.Chat, tr(lang, "import_busy"))
+		return
+	}
+
+	status, err := B.Send(m.Chat, importProgress(job), &tb.ReplyMarkup{InlineKeyboard: importCancelBtn(job)})
+	if err == nil {
+		importJobs.Update(job.ID, func(job *importJob) {
+			job.Status = tb.StoredMessage{MessageID: strconv.Itoa(status.ID), ChatID: status.Chat.ID}
+		})
+	}
+	go runImport(job.ID)
+}
+
+// resumeImports runs again the imports interrupted by a restart
+func resumeImports() {
+	for _, id := range importJobs.Unfinished() {
+		go runImport(id)
+	}
+}
+
+func runImport(id string) {
+	job := importJobs.Get(id)
+	if job == nil {
+		return
+	}
+
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		reportImportProgress(id, stop)
+		close(stopped)
+	}()
+
+	var wg sync.WaitGroup
+	for i, item := range job.Items {
+		if item.State != importPending {
+			continue
+		}
+
+		importSlots <- struct{}{}
+		if current := importJobs.Get(id); current == nil || current.Cancelled {
+			<-importSlots
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, item importItem) {
+			defer func() {
+				<-importSlots
+				wg.Done()
+			}()
+
+			if item.Discover {
+				item = discoverItem(item)
+			}
+			item.State = importDone
+			if err := importFeed(job.Owner, item); err != nil {
+				zap.S().Warnf("import %s for %d failed, err:%+v", item.URL, job.Owner, err)
+				item.State = importFailed
+			}
+			importJobs.Update(id, func(job *importJob) { job.Items[i] = item })
+		}(i, item)
+	}
+	wg.Wait()
+	close(stop)
+	<-stopped
+
+	now := time.Now()
+	importJobs.Update(id, func(job *importJob) { job.FinishedAt = &now })
+	finishImport(importJobs.Get(id))
+}
+
+// discoverItem replaces the web page of item by its feed like /sub does with a single url. A batch can not
+// offer a choice, the first feed of a page with several is taken.
+func discoverItem(item importItem) importItem {
+	candidates := discoverFeeds(item.URL)
+	if len(candidates) == 0 {
+		// a feed, or a page without feeds that fails to import
+		return item
+	}
+	item.URL = candidates[0].URL
+	if candidates[0].Title != "" {
+		item.Title = candidates[0].Title
+	}
+	return item
+}
+
+func importFeed(owner int64, item importItem) error {
+	source, err := model.FindOrNewSourceByUrl(item.URL)
+	if err != nil {
+		return err
+	}
+	// the settings of an export are restored on existing subscriptions too
+	if existing, _ := model.GetSubscribeByUserIDAndSourceID(owner, source.ID); existing == nil || item.Settings == nil {
+		if err := model.RegistFeed(owner, source.ID); err != nil {
+			return err
+		}
+		zap.S().Infof("%d subscribe [%d]%s %s", owner, source.ID, source.Title, source.Link)
+	}
+	if len(item.Tags) == 0 && item.Settings == nil {
+		return nil
+	}
+
+	sub, err := model.GetSubscribeByUserIDAndSourceID(owner, source.ID)
+	if err != nil {
+		return err
+	}
+	if len(item.Tags) > 0 {
+		if err := sub.SetTag(item.Tags); err != nil {
+			zap.S().Warnf("set tags of %s for %d failed, err:%+v", item.URL, owner, err)
+		}
+	}
+	if item.Settings != nil {
+		return applySettings(sub, item.Settings)
+	}
+	return nil
+}
+
+// reportImportProgress edits the status message of the import id every importProgressInterval until stop is closed
+func reportImportProgress(id string, stop <-chan struct{}) {
+	ticker := time.NewTicker(importProgressInterval)
+	defer ticker.Stop()
+
+	last := ""
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		job := importJobs.Get(id)
+		if job == nil || job.Status.MessageID == "" {
+			continue
+		}
+		// Telegram rejects edits that change nothing
+		if text := importProgress(job); text != last {
+			last = text
+			_, _ = B.Edit(job.Status, text, &tb.ReplyMarkup{InlineKeyboard: importCancelBtn(job)})
+		}
+	}
+}
+
+func finishImport(job *importJob) {
+	parts := splitMessage(importReport(job), 4096)
+	opt := &tb.SendOptions{DisableWebPagePreview: true, ParseMode: tb.ModeHTML}
+
+	if job.Status.MessageID == "" {
+		_, _ = B.Send(&tb.Chat{ID: job.ChatID}, parts[0], opt)
+	} else {
+		_, _ = B.Edit(job.Status, parts[0], opt)
+	}
+	for _, part := range parts[1:] {
+		_, _ = B.Send(&tb.Chat{ID: job.ChatID}, part, opt)
+	}
+}
+
+func importProgress(job *importJob) string {
+	done, failed, pending := job.counts()
+	if job.Cancelled {
+		return tr(job.Lang, "import_cancelling", done, failed, pending)
+	}
+	return tr(job.Lang, "import_progress", done, failed, pending, len(job.Items))
+}
+
+func importCancelBtn(job *importJob) [][]tb.InlineButton {
+	if job.FinishedAt != nil || job.Cancelled {
+		return nil
+	}
+	return [][]tb.InlineButton{
+		[]tb.InlineButton{
+			tb.InlineButton{
+				Unique: "import_cancel_btn",
+				Text:   tr(job.Lang, "btn_cancel"),
+				Data:   newCallback(job.ChatID, callbackPayload{Owner: job.Owner, Kind: "import", Arg: job.ID}),
+			},
+		},
+	}
+}
+
+func importReport(job *importJob) string {
+	var success, fail []importItem
+	for _, item := range job.Items {
+		switch item.State {
+		case importDone:
+			success = append(success, item)
+		case importFailed:
+			fail = append(fail, item)
+		}
+	}
+
+	report := tr(job.Lang, "import_report", len(success), len(fail))
+	if job.Cancelled {
+		report = tr(job.Lang, "import_cancelled", len(job.Items)-len(success)-len(fail)) + "\n" + report
+	}
+	if len(success) != 0 {
+		report += tr(job.Lang, "import_report_success") + formatImportItems(success)
+	}
+	if len(fail) != 0 {
+		report += tr(job.Lang, "import_report_fail") + formatImportItems(fail)
+	}
+	return report
+}
+
+func formatImportItems(items []importItem) string {
+	var text string
+	for i, item := range items {
+		if item.Title != "" {
+			text += fmt.Sprintf("\n[%d] <a href=\"%s\">%s</a>", i+1, html.EscapeString(item.URL), html.EscapeString(item.Title))
+		} else {
+			text += fmt.Sprintf("\n[%d] %s", i+1, html.EscapeString(item.URL))
+		}
+	}
+	return text
+}
+
+func importCancelBtnCtr(c *tb.Callback) {
+	payload, ok := loadCallback(c)
+	if !ok || payload.Kind != "import" || !callbackAuth(c, payload.Owner) {
+		return
+	}
+
+	job := importJobs.Get(payload.Arg)
+	if job == nil || job.FinishedAt != nil {
+		_ = B.Respond(c, &tb.CallbackResponse{Text: tr(cbLang(c), "import_not_running")})
+		return
+	}
+	importJobs.Update(job.ID, func(job *importJob) { job.Cancelled = true })
+	_ = B.Respond(c)
+
+	job = importJobs.Get(job.ID)
+	_, _ = B.Edit(c.Message, importProgress(job))
+}
+
+func importStatusCmdCtr(m *tb.Message) {
+	lang := msgLang(m)
+	if !canManageHere(m) {
+		return
+	}
+
+	job := importJobs.Latest(m.Chat.ID)
+	switch {
+	case job == nil:
+		_, _ = B.Send(m.Chat, tr(lang, "import_none"))
+	case job.FinishedAt != nil:
+		_, _ = B.Send(m.Chat, splitMessage(importReport(job), 4096)[0], &tb.SendOptions{
+			DisableWebPagePreview: true,
+			ParseMode:             tb.ModeHTML,
+		})
+	default:
+		_, _ = B.Send(m.Chat, importProgress(job), &tb.ReplyMarkup{InlineKeyboard: importCancelBtn(job)})
+	}
+}
@@ -0,0 +1,58 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeFeedURL(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{raw: "https://Example.com/rss/", want: "example.com/rss"},
+		{raw: "http://example.com:80/rss#latest", want: "example.com/rss"},
+		{raw: " https://example.com:8443/feed?lang=es ", want: "example.com:8443/feed?lang=es"},
+		{raw: "ftp://example.com/rss", wantErr: true},
+		{raw: "example.com/rss", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := normalizeFeedURL(tt.raw)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("normalizeFeedURL(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("normalizeFeedURL(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestMergeDuplicateItems(t *testing.T) {
+	items := []importItem{
+		{Title: "Go", URL: "https://go.dev/blog/feed.atom", Tags: []string{"Tech"}},
+		{Title: "News", URL: "https://example.com/rss"},
+		{Title: "Go", URL: "http://go.dev/blog/feed.atom/", Tags: []string{"Go"}},
+		{Title: "Go", URL: "https://go.dev/blog/feed.atom", Tags: []string{"Tech"}},
+		{Title: "Bad", URL: "not a feed"},
+	}
+	wantUnique := []importItem{
+		{Title: "Go", URL: "https://go.dev/blog/feed.atom", Tags: []string{"Tech", "Go"}},
+		{Title: "News", URL: "https://example.com/rss"},
+		{Title: "Bad", URL: "not a feed"},
+	}
+	wantDuplicates := []importItem{items[3]}
+
+	unique, duplicates := mergeDuplicateItems(items)
+	if !reflect.DeepEqual(unique, wantUnique) {
+		t.Errorf("mergeDuplicateItems() unique = %+v, want %+v", unique, wantUnique)
+	}
+	if !reflect.DeepEqual(duplicates, wantDuplicates) {
+		t.Errorf("mergeDuplicateItems() duplicates = %+v, want %+v", duplicates, wantDuplicates)
+	}
+	if !reflect.DeepEqual(items[0].Tags, []string{"Tech"}) {
+		t.Errorf("mergeDuplicateItems() changed the tags of its input: %q", items[0].Tags)
+	}
+}
@@ -0,0 +1,259 @@
+package bot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// importer reads the subscriptions of a file exported by a feed reader
+type importer interface {
+	// Name is shown in the import preview
+	Name() string
+	// Sniff reports whether data looks like the format of the importer
+	Sniff(data []byte) bool
+	Parse(data []byte) ([]importItem, error)
+}
+
+// utf8BOM starts the files of some editors and feed readers, it is skipped before sniffing
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// importers are tried in order, the most specific formats first
+var importers []importer
+
+// registerImporter adds imp after the importers already registered
+func registerImporter(imp importer) {
+	importers = append(importers, imp)
+}
+
+func init() {
+	registerImporter(exportJSONImporter{})
+	registerImporter(exportCSVImporter{})
+	registerImporter(opmlImporter{})
+	registerImporter(greaderImporter{})
+	registerImporter(minifluxImporter{})
+	registerImporter(newsboatImporter{})
+	registerImporter(textImporter{})
+}
+
+// parseImportFile picks the importer of data by its content and returns the feeds it contains
+func parseImportFile(data []byte) ([]importItem, string, error) {
+	data = bytes.TrimPrefix(data, utf8BOM)
+	for _, imp := range importers {
+		if !imp.Sniff(data) {
+			continue
+		}
+		items, err := imp.Parse(data)
+		if err != nil {
+			return nil, imp.Name(), err
+		}
+		if len(items) == 0 {
+			return nil, imp.Name(), fmt.Errorf("no feeds in %s file", imp.Name())
+		}
+		return items, imp.Name(), nil
+	}
+	return nil, "", fmt.Errorf("unknown import format")
+}
+
+type opmlImporter struct{}
+
+func (opmlImporter) Name() string { return "OPML" }
+
+func (opmlImporter) Sniff(data []byte) bool {
+	// the OPML sync sniffs the files it fetches without parseImportFile
+	head := bytes.TrimPrefix(data, utf8BOM)
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	head = bytes.ToLower(head)
+	return bytes.HasPrefix(bytes.TrimSpace(head), []byte("<")) && bytes.Contains(head, []byte("<opml"))
+}
+
+func (opmlImporter) Parse(data []byte) ([]importItem, error) {
+	return parseOPML(data)
+}
+
+// greaderImporter reads the subscription lists of Feedly and Inoreader, which follow the Google Reader API:
+// [{"id": "feed/<url>", "title": ..., "categories": [{"label": ...}]}], bare or in "subscriptions"
+type greaderImporter struct{}
+
+type greaderSubscription struct {
+	ID         string `json:"id"`
+	Title      string `json:"title"`
+	URL        string `json:"url"`
+	Categories []struct {
+		Label string `json:"label"`
+	} `json:"categories"`
+}
+
+func (greaderImporter) Name() string { return "Feedly/Inoreader JSON" }
+
+func (greaderImporter) decode(data []byte) ([]greaderSubscription, error) {
+	var subs []greaderSubscription
+	if err := json.Unmarshal(data, &subs); err == nil {
+		return subs, nil
+	}
+	var wrapped struct {
+		Subscriptions []greaderSubscription `json:"subscriptions"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return nil, err
+	}
+	return wrapped.Subscriptions, nil
+}
+
+func (g greaderImporter) Sniff(data []byte) bool {
+	subs, err := g.decode(data)
+	return err == nil && len(subs) > 0 && strings.HasPrefix(subs[0].ID, "feed/")
+}
+
+func (g greaderImporter) Parse(data []byte) ([]importItem, error) {
+	subs, err := g.decode(data)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]importItem, 0, len(subs))
+	for _, sub := range subs {
+		url := sub.URL
+		if url == "" {
+			url = strings.TrimPrefix(sub.ID, "feed/")
+		}
+		var labels []string
+		for _, category := range sub.Categories {
+			labels = append(labels, category.Label)
+		}
+		items = append(items, importItem{Title: sub.Title, URL: url, Tags: importTags(labels)})
+	}
+	return items, nil
+}
+
+// minifluxImporter reads the feed list of the Miniflux API, GET /v1/feeds
+type minifluxImporter struct{}
+
+type minifluxFeed struct {
+	FeedURL  string `json:"feed_url"`
+	Title    string `json:"title"`
+	Category *struct {
+		Title string `json:"title"`
+	} `json:"category"`
+}
+
+func (minifluxImporter) Name() string { return "Miniflux JSON" }
+
+func (minifluxImporter) Sniff(data []byte) bool {
+	var feeds []minifluxFeed
+	return json.Unmarshal(data, &feeds) == nil && len(feeds) > 0 && feeds[0].FeedURL != ""
+}
+
+func (minifluxImporter) Parse(data []byte) ([]importItem, error) {
+	var feeds []minifluxFeed
+	if err := json.Unmarshal(data, &feeds); err != nil {
+		return nil, err
+	}
+	items := make([]importItem, 0, len(feeds))
+	for _, feed := range feeds {
+		item := importItem{Title: feed.Title, URL: feed.FeedURL}
+		if feed.Category != nil {
+			item.Tags = importTags([]string{feed.Category.Title})
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// newsboatImporter reads newsboat urls files: one `url tag "tag with spaces" ~title` per line
+type newsboatImporter struct{}
+
+func (newsboatImporter) Name() string { return "newsboat" }
+
+// Sniff accepts files where every line is a comment, a query feed or starts with an url
+func (newsboatImporter) Sniff(data []byte) bool {
+	found := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, `"query:`):
+		case strings.HasPrefix(line, "http://") || strings.HasPrefix(line, "https://"):
+			found = true
+		default:
+			return false
+		}
+	}
+	return found
+}
+
+func (newsboatImporter) Parse(data []byte) ([]importItem, error) {
+	var items []importItem
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := splitQuoted(scanner.Text())
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], "http") {
+			continue
+		}
+
+		item := importItem{URL: fields[0]}
+		var tags []string
+		for _, field := range fields[1:] {
+			switch {
+			case strings.HasPrefix(field, "~"):
+				item.Title = field[1:]
+			case strings.HasPrefix(field, "!"):
+				// hidden feed marker
+			default:
+				tags = append(tags, field)
+			}
+		}
+		item.Tags = importTags(tags)
+		items = append(items, item)
+	}
+	return items, scanner.Err()
+}
+
+// splitQuoted splits line on spaces outside double quotes, it stops at a # comment
+func splitQuoted(line string) []string {
+	var fields []string
+	var field strings.Builder
+	quoted := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == '#' && !quoted && field.Len() == 0:
+			return fields
+		case (r == ' ' || r == '\t') && !quoted:
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteRune(r)
+		}
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// textImporter takes every url found in a text file
+type textImporter struct{}
+
+var textURLRegexp = regexp.MustCompile(`https?://[^\s"'<>]+`)
+
+func (textImporter) Name() string { return "text" }
+
+func (textImporter) Sniff(data []byte) bool {
+	return textURLRegexp.Match(data)
+}
+
+func (textImporter) Parse(data []byte) ([]importItem, error) {
+	var items []importItem
+	for _, url := range textURLRegexp.FindAll(data, -1) {
+		items = append(items, importItem{URL: strings.TrimRight(string(url), ".,;)")})
+	}
+	return items, nil
+}
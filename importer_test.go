@@ -0,0 +1,174 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseImportFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format string
+		items  []importItem
+	}{
+		{
+			name: "opml",
+			data: `<?xml version="1.0"?>
+<opml version="1.0"><body>
+<outline text="Tech"><outline text="Go" xmlUrl="https://go.dev/blog/feed.atom"/></outline>
+<outline text="News" xmlUrl="https://example.com/rss"/>
+</body></opml>`,
+			format: "OPML",
+			items: []importItem{
+				{Title: "Go", URL: "https://go.dev/blog/feed.atom", Tags: []string{"Tech"}},
+				{Title: "News", URL: "https://example.com/rss"},
+			},
+		},
+		{
+			name:   "feedly",
+			data:   `[{"id": "feed/https://example.com/rss", "title": "Example", "categories": [{"label": "Daily news"}]}]`,
+			format: "Feedly/Inoreader JSON",
+			items:  []importItem{{Title: "Example", URL: "https://example.com/rss", Tags: []string{"Daily_news"}}},
+		},
+		{
+			name:   "inoreader",
+			data:   `{"subscriptions": [{"id": "feed/https://example.com/rss", "title": "Example", "url": "https://example.com/feed"}]}`,
+			format: "Feedly/Inoreader JSON",
+			items:  []importItem{{Title: "Example", URL: "https://example.com/feed"}},
+		},
+		{
+			name:   "miniflux",
+			data:   `[{"feed_url": "https://example.com/rss", "title": "Example", "category": {"title": "All"}}]`,
+			format: "Miniflux JSON",
+			items:  []importItem{{Title: "Example", URL: "https://example.com/rss", Tags: []string{"All"}}},
+		},
+		{
+			name: "newsboat",
+			data: `# my feeds
+https://example.com/rss tech "long tag" ~Example
+"query:unread:unread = \"yes\""
+https://example.org/atom.xml !`,
+			format: "newsboat",
+			items: []importItem{
+				{Title: "Example", URL: "https://example.com/rss", Tags: []string{"tech", "long_tag"}},
+				{URL: "https://example.org/atom.xml"},
+			},
+		},
+		{
+			name:   "text",
+			data:   "my feeds: https://example.com/rss, and (https://example.org/atom.xml).",
+			format: "text",
+			items:  []importItem{{URL: "https://example.com/rss"}, {URL: "https://example.org/atom.xml"}},
+		},
+		{
+			name:   "flowerss csv",
+			data:   "url,title,tags,interval,notification,telegraph,paused,delivery,filters\nhttps://example.com/rss,Example,go,10,true,false,true,daily,-ads\n",
+			format: "flowerss CSV",
+			items: []importItem{{
+				Title: "Example", URL: "https://example.com/rss", Tags: []string{"go"},
+				Settings: &subSettings{Interval: 10, Notification: true, Paused: true, Delivery: "daily", Filters: []string{"-ads"}},
+			}},
+		},
+		{
+			name:   "opml with bom",
+			data:   "\xef\xbb\xbf<?xml version=\"1.0\"?>\n<opml version=\"2.0\"><body><outline text=\"Tech\"><outline text=\"Go\" xmlUrl=\"https://go.dev/blog/feed.atom\" htmlUrl=\"https://go.dev/blog\"/></outline></body></opml>",
+			format: "OPML",
+			items:  []importItem{{Title: "Go", URL: "https://go.dev/blog/feed.atom", Tags: []string{"Tech"}}},
+		},
+		{
+			name:   "flowerss csv with bom",
+			data:   "\xef\xbb\xbfurl,title,tags,interval,notification,telegraph,paused,delivery,filters\nhttps://example.com/rss,Example,,10,false,false,false,,\n",
+			format: "flowerss CSV",
+			items: []importItem{{
+				Title: "Example", URL: "https://example.com/rss", Settings: &subSettings{Interval: 10},
+			}},
+		},
+		{
+			name:   "flowerss json with bom",
+			data:   "\xef\xbb\xbf" + `{"format": "flowerss-export", "version": 1, "subscriptions": [{"url": "https://example.com/rss", "title": "Example"}]}`,
+			format: "flowerss JSON",
+			items:  []importItem{{Title: "Example", URL: "https://example.com/rss", Settings: &subSettings{}}},
+		},
+		{
+			name:   "flowerss json",
+			data:   `{"format": "flowerss-export", "version": 1, "subscriptions": [{"url": "https://example.com/rss", "title": "Example", "interval": 5}]}`,
+			format: "flowerss JSON",
+			items: []importItem{{
+				Title: "Example", URL: "https://example.com/rss", Settings: &subSettings{Interval: 5},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, format, err := parseImportFile([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("parseImportFile() error = %v", err)
+			}
+			if format != tt.format {
+				t.Errorf("parseImportFile() format = %q, want %q", format, tt.format)
+			}
+			if !reflect.DeepEqual(items, tt.items) {
+				t.Errorf("parseImportFile() items = %+v, want %+v", items, tt.items)
+			}
+		})
+	}
+}
+
+func TestParseImportFileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"no url", "nothing to import here"},
+		{"empty opml", `<opml version="2.0"><body></body></opml>`},
+		{"newer export", `{"format": "flowerss-export", "version": 99, "subscriptions": []}`},
+		{"bad csv line", "url,title,tags,interval,notification,telegraph,paused,delivery,filters\nhttps://example.com/rss,Example,,ten,true,false,false,,\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if items, _, err := parseImportFile([]byte(tt.data)); err == nil {
+				t.Errorf("parseImportFile() = %+v, want an error", items)
+			}
+		})
+	}
+}
+
+func TestOPMLImporterSniff(t *testing.T) {
+	tests := []struct {
+		data string
+		want bool
+	}{
+		{`<?xml version="1.0"?><opml version="2.0"></opml>`, true},
+		{"  <OPML>", true},
+		{"\xef\xbb\xbf<?xml version=\"1.0\"?><opml version=\"2.0\">", true},
+		{`<html><body><a href="https://example.com/rss">feed</a></body></html>`, false},
+		{"https://example.com/opml", false},
+	}
+
+	for _, tt := range tests {
+		if got := (opmlImporter{}).Sniff([]byte(tt.data)); got != tt.want {
+			t.Errorf("Sniff(%q) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestSplitQuoted(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{`https://example.com/rss tag "two words"`, []string{"https://example.com/rss", "tag", "two words"}},
+		{"https://example.com/rss\t~Title # comment", []string{"https://example.com/rss", "~Title"}},
+		{"# only a comment", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		if got := splitQuoted(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitQuoted(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
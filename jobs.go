@@ -0,0 +1,10 @@
+package bot
+
+// startJobs starts the background jobs of the bot, Start calls it before polling
+func startJobs() {
+	go digestLoop()
+	go backupLoop()
+	go opmlSyncLoop()
+	go flushLoop()
+	resumeImports()
+}
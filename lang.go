@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+	"sync"
+
+	"github.com/indes/flowerss-bot/bot/i18n"
+	"github.com/indes/flowerss-bot/config"
+	"github.com/indes/flowerss-bot/model"
+	"go.uber.org/zap"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const defaultLang = "es"
+
+// LocaleDir is the directory translation files (<lang>.json) are loaded from
+var LocaleDir = "locales"
+
+var (
+	catalog     = i18n.New(defaultLang)
+	catalogOnce sync.Once
+)
+
+func messages() *i18n.Catalog {
+	catalogOnce.Do(func() {
+		catalog.Add(defaultLang, esMessages)
+		if err := catalog.LoadDir(LocaleDir); err != nil {
+			zap.S().Errorf("load locales from %s failed, err:%+v", LocaleDir, err)
+		}
+	})
+	return catalog
+}
+
+// tr returns the message of key in lang, formatted with args
+func tr(lang, key string, args ...interface{}) string {
+	return messages().Sprintf(lang, key, args...)
+}
+
+// chatLang returns the language chosen with /lang, or the language of the user's Telegram client
+func chatLang(chat *tb.Chat, user *tb.User) string {
+	if chat != nil {
+		if lang := chatOptions.Get(chat.ID).Lang; lang != "" {
+			return lang
+		}
+	}
+	if user != nil && user.LanguageCode != "" && messages().Supports(user.LanguageCode) {
+		return i18n.Normalize(user.LanguageCode)
+	}
+	return defaultLang
+}
+
+func msgLang(m *tb.Message) string {
+	return chatLang(m.Chat, m.Sender)
+}
+
+func cbLang(c *tb.Callback) string {
+	return chatLang(c.Message.Chat, c.Sender)
+}
+
+// renderFeedSetting renders the subscription setting card
+func renderFeedSetting(lang string, source *model.Source, sub *model.Subscribe) string {
+	t := template.New("setting template")
+	_, _ = t.Parse(tr(lang, "feed_setting_tmpl"))
+	text := new(bytes.Buffer)
+	opt := subOptions.Get(sub.ID)
+	_ = t.Execute(text, map[string]interface{}{
+		"source":   source,
+		"sub":      sub,
+		"paused":   opt.Paused,
+		"filters":  formatFilters(opt.Filters),
+		"filtered": opt.Filtered,
+		"delivery": deliveryModeOf(sub, opt).name(lang),
+		"Count":    config.ErrorThreshold,
+	})
+	return text.String()
+}
+
+func langCmdCtr(m *tb.Message) {
+	lang := msgLang(m)
+	if !canManageHere(m) {
+		return
+	}
+
+	code := i18n.Normalize(m.Payload)
+	switch {
+	case code == "":
+		_, _ = B.Send(m.Chat, tr(lang, "lang_current", lang, strings.Join(messages().Languages(), ", ")))
+		return
+	case code == "auto":
+		lang = chatLang(nil, m.Sender)
+	case messages().Supports(code):
+		lang = code
+	default:
+		_, _ = B.Send(m.Chat, tr(lang, "lang_unsupported", code, strings.Join(messages().Languages(), ", ")))
+		return
+	}
+
+	err := chatOptions.Update(m.Chat.ID, func(opt *ChatOption) {
+		if code == "auto" {
+			opt.Lang = ""
+		} else {
+			opt.Lang = code
+		}
+	})
+	if err != nil {
+		zap.S().Errorf("save chat %d language failed, err:%+v", m.Chat.ID, err)
+		_, _ = B.Send(m.Chat, tr(lang, "lang_failed"))
+		return
+	}
+	_, _ = B.Send(m.Chat, tr(lang, "lang_set", lang))
+	zap.S().Infof("%d set language %s", m.Chat.ID, lang)
+}
@@ -0,0 +1,250 @@
+package bot
+
+// esMessages is the built-in default catalog, other languages are loaded from LocaleDir
+var esMessages = map[string]string{
+	"feed_setting_tmpl": `
+Suscripcion<b>Configurar</b>
+[id] {{ .sub.ID }}
+[Titulo] {{ .source.Title }}
+[Link] {{.source.Link }}
+[Obtener actualizaciones] {{if ge .source.ErrorCount .Count }}se acab0 el tiempo{{else if .paused }}Pausado{{else}}Cerrar{{end}}
+[Frecuencia de rastreo] {{ .sub.Interval }}minuto
+[!] {{if eq .sub.EnableNotification 0}}Cerrar{{else if eq .sub.EnableNotification 1}}Enceneder{{end}}
+[Telegraph] {{if eq .sub.EnableTelegraph 0}}Cerrar{{else if eq .sub.EnableTelegraph 1}}Enceneder{{end}}
+[Tag] {{if .sub.Tag}}{{ .sub.Tag }}{{else}}No{{end}}
+[Filtros] {{if .filters}}{{ .filters }} ({{ .filtered }} filtrados){{else}}No{{end}}
+[Entrega] {{ .delivery }}
+`,
+
+	"error":             "error",
+	"callback_expired":  "Este botón ha caducado, vuelva a ejecutar el comando",
+	"modify_success":    "Modificado con éxito",
+	"start_welcome":     "Hola, bienvenido a flowerss. ",
+	"not_channel_admin": "Los administradores que no son de canal no pueden realizar esta operación",
+	"channel_error":     "Error al obtener la información del canal.",
+	"no_feeds":          "Actualmente no hay feeds",
+	"sub_list_empty":    "La lista de suscripciones está vacía",
+	"invalid_sub_id":    "Ingrese el ID de suscripción correcto!",
+	"permission_denied": "¡Permiso denegado!",
+	"choose_correct":    "Elija la instrucción correcta！",
+	"bot_error":         "Error de bot, póngase en contacto con el administrador. Código de error %02d",
+	"system_error":      "error del sistema，Código %02d",
+	"processing":        "processing",
+
+	"sub_reply_url":             "Responda con la URL de RSS, puede enviar varias separadas por espacios o saltos de línea",
+	"sub_reply_correct":         "Responde a la URL correcta.",
+	"sub_channel_usage":         "Para suscribirse al canal, utilice el comando '/sub @ChannelID URL [URL...]'",
+	"sub_discover_choose":       "%s tiene varios feeds, elija el que desea suscribir:",
+	"sub_discover_success":      "Suscrito a %s",
+	"sub_discover_failed":       "No se pudo suscribir a %s",
+	"preview_usage":             "/preview URL Ver un feed antes de suscribirse",
+	"preview_failed":            "No se pudo leer el feed %s: %s",
+	"preview_title":             "<b>%s</b>\nTipo: %s\nEntradas: %d\nFrecuencia estimada: %s\n\n<b>Últimas entradas:</b>",
+	"preview_no_items":          "El feed no tiene entradas",
+	"preview_frequency_unknown": "desconocida",
+	"preview_frequency_minutes": "una entrada cada %d minutos",
+	"preview_frequency_hours":   "una entrada cada %d horas",
+	"preview_frequency_days":    "una entrada cada %d días",
+	"btn_preview_sub":           "Suscribir",
+	"backfill_usage":            "/sub URL --last N envía las N últimas entradas al suscribirse\n/backfill [@canal] N cambia el valor por defecto del chat, de 0 a %d",
+	"backfill_single_url":       "--last solo se puede usar al suscribirse a una única URL",
+	"backfill_status":           "Al suscribirse se envían las %d últimas entradas.\n\n/backfill [@canal] N para cambiarlo, /sub URL --last N para una suscripción",
+	"backfill_set":              "Al suscribirse se enviarán las %d últimas entradas",
+	"last_usage":                "/last <id de suscripción> [n] Ver las últimas entradas de una suscripción, hasta %d",
+	"last_title":                "<b>%s</b>, últimas %d entradas:",
+	"last_empty":                "Todavía no hay entradas guardadas de este feed",
+	"last_page_title":           "Últimas entradas de %s",
+	"search_usage":              "/search [@canal] [#etiqueta] [desde:AAAA-MM-DD] [hasta:AAAA-MM-DD] palabras\nBuscar en los títulos y el contenido de las entradas enviadas a este chat",
+	"search_results":            "<b>%d resultados para</b> %s",
+	"search_no_results":         "No hay entradas enviadas que coincidan con %s",
+	"watch_usage":               "/watch [@canal] palabra o /regex/ Avisar de las entradas de cualquier suscripción que coincidan, aunque las notificaciones estén desactivadas\n/watches Ver y eliminar los avisos",
+	"watch_invalid":             "Aviso no válido: %s",
+	"watch_too_many":            "No se pueden añadir más de %d avisos",
+	"watch_list_empty":          "No hay avisos.\n\n/watch palabra o /regex/ para añadir uno",
+	"watch_list_title":          "<b>Avisos</b> (coincidencias entre paréntesis):",
+	"watch_alert":               "<b>Aviso</b> %s",
+	"btn_watch_delete":          "Eliminar %s",
+
+	"export_failed":            "Exportación fallida",
+	"export_usage":             "/export [opml|json|csv] [@canal] Exportar las suscripciones, json y csv incluyen toda la configuración",
+	"backup_usage":             "/backup [daily|weekly|off] [opml|json|csv] [@canal] Enviar una copia de seguridad periódica de las suscripciones a este chat",
+	"backup_off":               "Las copias de seguridad periódicas están desactivadas.\n\n/backup [daily|weekly] [opml|json|csv] [@canal] para activarlas",
+	"backup_status":            "Copia de seguridad %s en formato %s, última: %s\n\n/backup off para desactivarla",
+	"backup_enabled":           "Copia de seguridad %s en formato %s activada, cada copia reemplaza a la anterior",
+	"backup_disabled":          "Copia de seguridad periódica desactivada",
+	"backup_caption":           "Copia de seguridad de %d suscripciones, %s",
+	"syncopml_usage":           "/syncopml [@canal] URL [remove] Sincronizar cada hora las suscripciones con un archivo OPML remoto, remove cancela las suscripciones que no están en el archivo\n/syncopml [@canal] now Sincronizar ahora\n/syncopml [@canal] off Dejar de sincronizar",
+	"syncopml_status":          "Sincronizado con %s (%s), última sincronización: %s\n\n/syncopml now para sincronizar ahora, /syncopml off para dejar de sincronizar",
+	"syncopml_last_error":      "Último error: %s",
+	"syncopml_mode_keep":       "las suscripciones que faltan en el archivo se conservan",
+	"syncopml_mode_remove":     "las suscripciones que faltan en el archivo se cancelan",
+	"syncopml_enabled":         "Las suscripciones se sincronizan cada hora con %s, %s. Se enviará un informe cuando algo cambie.",
+	"syncopml_disabled":        "Sincronización OPML desactivada",
+	"syncopml_failed":          "La sincronización con %s falló: %s",
+	"syncopml_report":          "Sincronización con %s\nAñadidas: %d, etiquetas actualizadas: %d, canceladas: %d, fallidas: %d",
+	"syncopml_report_added":    "<b>Añadidas:</b>",
+	"syncopml_report_retagged": "<b>Etiquetas actualizadas:</b>",
+	"syncopml_report_removed":  "<b>Canceladas:</b>",
+	"syncopml_report_missing":  "<b>No están en el archivo (use remove para cancelarlas):</b>",
+	"syncopml_report_failed":   "<b>Fallidas:</b>",
+	"syncopml_report_invalid":  "<b>URL no válidas:</b>",
+
+	"list_error":         "Lista de errores internos @%d",
+	"list_title":         "Lista de suscripción actual：\n",
+	"list_channel_title": "Canal [%s] (https://t.me/%s) Lista de suscripción: \n",
+	"list_channel_empty": "La lista de suscripción del canal [%s] (https://t.me/%s) está vacía",
+
+	"check_title":         "Lista de suscripciones caducadas：\n",
+	"check_ok":            "Todas las suscripciones son normales ",
+	"check_channel_title": "Lista del canal [%s] (https://t.me/%s) de suscripciones caducadas: \n",
+	"check_channel_ok":    "Canal [%s] (https://t.me/%s) Todas las suscripciones son normales",
+
+	"set_channel_no_feeds": "Channel no tiene feeds.",
+	"set_choose_feed":      "Seleccione la fuente que desea configurar",
+	"set_feed_not_found":   "No se pudo encontrar el feed, código de error 01.",
+	"set_not_subscribed":   "El usuario no se ha suscrito al rss, código de error 02.",
+	"set_tag_reply":        "Responda con las etiquetas de la suscripción %d, separadas por espacios (hasta tres etiquetas)",
+
+	"btn_set_tag":        "Configuración de etiquetas",
+	"btn_notice_on":      "Activar notificación",
+	"btn_notice_off":     "Cerrar notificación",
+	"btn_telegraph_on":   "Activar la transcodificación Telegraph",
+	"btn_telegraph_off":  "Desactivar la transcodificación Telegraph",
+	"btn_update_pause":   "Pausar actualización",
+	"btn_update_restart": "Reiniciar actualización",
+	"btn_update_resume":  "Reanudar actualización",
+	"btn_confirm":        "confirmar",
+	"btn_cancel":         "cancelar",
+	"btn_last":           "Últimos",
+	"btn_set_filter":     "Filtros",
+	"btn_filter_delete":  "Eliminar %s",
+	"btn_delivery":       "Entrega: %s",
+	"btn_back":           "Volver",
+	"page_of":            "Página %d de %d",
+
+	"unsub_not_subscribed":         "No suscrito a este canal RSS",
+	"unsub_success":                "[%s](%s) Dado de baja con éxito！",
+	"unsub_choose_feed":            "Seleccione la fuente de la que desea cancelar la suscripción",
+	"unsub_channel_not_subscribed": "Canal [%s](https://t.me/%s) No suscrito a este canal RSS",
+	"unsub_failed":                 "No se pudo cancelar la suscripción",
+	"unsub_channel_success":        "Canal [%s](https://t.me/%s) Darse de baja [%s](%s) éxito",
+	"unsub_item_success":           "[%d] <a href=\"%s\">%s</a> Darse de baja con éxito",
+	"unsub_item_failed":            "Error de cancelación de suscripción！",
+
+	"unsuball_confirm":         "Desea cancelar todas las suscripciones del usuario actual？",
+	"unsuball_channel_confirm": "%s Desea darse de baja Channel Todas las suscripciones？",
+	"unsuball_result":          "Darse de baja con éxito：%d\nNo se pudo cancelar la suscripción：%d",
+	"operation_cancelled":      "Operación cancelada",
+
+	"help": `
+Comandos：
+/sub Alimentar
+/preview Ver un feed antes de suscribirse
+/backfill Entradas enviadas al suscribirse
+/last Ver las últimas entradas de una suscripción
+/search Buscar en las entradas enviadas
+/watch Avisar de las entradas que contengan una palabra
+/watches Ver y eliminar los avisos
+/unsub  darse de baja
+/list Ver feeds actuales
+/set Configurar suscripción
+/check Verificar suscripción actual
+/setfeedtag Establecer etiqueta de suscripción
+/setfilter Establecer filtros de suscripción
+/digest Configurar los resúmenes
+/setinterval Establecer la frecuencia de actualización de la suscripción
+/activeall Activar todas las suscripciones
+/pauseall Suspender todas las suscripciones
+/lang Cambiar el idioma del chat
+/use Gestionar un canal desde este chat
+/channels Ver los canales y grupos que administra
+/cancel Cancelar la operación en curso
+/help ayuda
+/import Importar archivos OPML
+/importstatus Ver el estado de la importación
+/export Exportar las suscripciones (opml, json o csv)
+/backup Copia de seguridad periódica de las suscripciones
+/syncopml Sincronizar las suscripciones con un archivo OPML remoto
+/unsuball Cancelar todas las suscripciones
+Para obtener información detallada sobre el uso, consulte：https://github.com/indes/flowerss-bot
+`,
+	"import_help": `Envíe el archivo directamente: OPML, exportación JSON de Feedly, Inoreader o Miniflux, archivo urls de newsboat o una lista de URL en texto，
+Si necesita importar para el canal, incluya la identificación del canal al enviar el archivo, como @telegram
+`,
+
+	"setfeedtag_usage": "/setfeedtag [sub id] [tag1] [tag2] Establecer etiquetas de suscripción (configurar hasta tres etiquetas, separadas por espacios）",
+	"settag_failed":    "No se pudo establecer la etiqueta de suscripción!",
+	"settag_success":   "La etiqueta de suscripción se estableció correctamente!",
+
+	"setinterval_usage":   "/setinterval [interval] [sub id] Establecer la frecuencia de actualización de la suscripción (se pueden establecer múltiples sub id, separados por espacios）",
+	"invalid_interval":    "Ingrese la frecuencia de rastreo correcta",
+	"setinterval_success": "La frecuencia de rastreo se estableció correctamente!",
+
+	"activeall":         "Todas las suscripciones están activadas",
+	"activeall_channel": "Canal [%s](https://t.me/%s) Todas las suscripciones están activadas",
+	"pauseall":          "Todas las suscripciones están suspendidas",
+	"pauseall_channel":  "Canal [%s](https://t.me/%s) Todas las suscripciones están suspendidas",
+
+	"import_download_failed":    "No se pudo descargar el archivo OPML. Verifique si el servidor bot puede conectarse al servidor de Telegram o intente importarlo más tarde. Código de error 02",
+	"import_bad_file":           "Formato de archivo no reconocido. Envíe un archivo OPML, una exportación JSON de Feedly, Inoreader o Miniflux, un archivo urls de newsboat o una lista de URL. Código de error 01, doc mimetype: %s",
+	"import_report":             "<b>Importación exitosa: %d, error de importación：%d</b>",
+	"import_report_success":     "\n\n<b>Los siguientes feeds se importaron correctamente:</b>",
+	"import_report_fail":        "\n\n<b>No se pudo importar el siguiente feed:</b>",
+	"import_progress":           "Importando: %d importados, %d con errores, %d pendientes de %d",
+	"import_cancelling":         "Cancelando la importación: %d importados, %d con errores, %d sin importar",
+	"import_cancelled":          "<b>Importación cancelada, %d feeds sin importar</b>",
+	"import_busy":               "Ya hay una importación en curso en este chat, consulte /importstatus",
+	"import_not_running":        "Esta importación ya ha terminado",
+	"import_none":               "No hay importaciones recientes en este chat",
+	"import_preview":            "<b>Vista previa de la importación (%s)</b>\nNuevos: %d\nSe actualizarán: %d\nYa suscritos: %d\nDuplicados: %d\nNo válidos: %d",
+	"import_preview_new":        "<b>Se suscribirán:</b>",
+	"import_preview_update":     "<b>Ya suscritos, se restaurará su configuración:</b>",
+	"import_preview_subscribed": "<b>Ya suscritos, se omitirán:</b>",
+	"import_preview_duplicate":  "<b>Duplicados en el archivo, se omitirán:</b>",
+	"import_preview_invalid":    "<b>URL no válida, se omitirán:</b>",
+	"import_preview_more":       "... y %d más",
+	"import_preview_nothing":    "No hay feeds nuevos que importar.",
+	"btn_import_confirm":        "Importar %d",
+
+	"lang_current":     "Idioma actual: %s\nIdiomas disponibles: %s\nUso: /lang <código>, /lang auto",
+	"lang_unsupported": "Idioma %s no disponible. Idiomas disponibles: %s",
+	"lang_set":         "Idioma cambiado a %s",
+	"lang_failed":      "No se pudo cambiar el idioma",
+
+	"cancel_nothing": "No hay ninguna operación pendiente",
+
+	"setfilter_usage":    "/setfilter [sub id] [+|-][title:]palabra|/regex/ Añadir un filtro: + solo reenvía los elementos que coinciden, - descarta los que coinciden, title: solo mira el título\n/setfilter [sub id] del [n] Eliminar el filtro n\n/setfilter [sub id] clear Eliminar todos los filtros",
+	"setfilter_hint":     "Use <code>/setfilter %d +palabra</code> o <code>/setfilter %d -title:/regex/</code> para añadir filtros",
+	"setfilter_invalid":  "Filtro no válido: %s",
+	"setfilter_too_many": "Se pueden configurar hasta %d filtros",
+	"setfilter_failed":   "No se pudieron guardar los filtros",
+	"filter_list_empty":  "La suscripción %d no tiene filtros",
+	"filter_list_title":  "<b>Filtros de la suscripción %d</b> (%d elementos filtrados)",
+	"list_filtered":      " (%d filtrados)",
+	"list_filtered_by":   " (%d filtrados: %s)",
+
+	"delivery_immediate": "inmediata",
+	"delivery_inherit":   "según sus etiquetas",
+	"delivery_hourly":    "resumen cada hora",
+	"delivery_daily":     "resumen diario",
+	"delivery_weekly":    "resumen semanal",
+	"digest_title":       "<b>Resumen</b> (%d novedades)",
+	"digest_page_title":  "Resumen %s",
+	"digest_setting":     "Zona horaria: %s\nHora del resumen: %02d:%02d\nDía del resumen semanal: %s",
+	"digest_usage":       "/digest [@ChannelID] tz Europe/Madrid Establecer la zona horaria\n/digest [@ChannelID] time 08:00 Establecer la hora de los resúmenes diarios y semanales\n/digest [@ChannelID] day mon Establecer el día del resumen semanal\n/digest [@ChannelID] tag [tag] immediate|hourly|daily|weekly Establecer la entrega de las suscripciones con la etiqueta",
+	"digest_bad_tz":      "Zona horaria desconocida: %s",
+	"digest_failed":      "No se pudo guardar la configuración del resumen",
+
+	"use_usage":         "/use @canal Gestionar un canal sin indicarlo en cada comando, /use me para volver a este chat",
+	"use_private_only":  "/use solo está disponible en el chat privado con el bot",
+	"use_current_me":    "Los comandos actúan sobre este chat. /use @canal para gestionar un canal",
+	"use_current":       "Los comandos actúan sobre %s. /use me para volver a este chat",
+	"use_set":           "Ahora los comandos actúan sobre %s. /use me para volver a este chat",
+	"use_reset":         "Los comandos vuelven a actuar sobre este chat",
+	"use_target_lost":   "Ya no administra %s, los comandos vuelven a actuar sobre este chat",
+	"channels_title":    "<b>Canales y grupos que administra</b>",
+	"channels_item":     "%s: %d suscripciones, %d con errores",
+	"channels_empty":    "No administra ningún canal o grupo con suscripciones. Use /sub @canal URL o /use @canal para empezar",
+	"btn_channel_set":   "Configurar",
+	"btn_channel_check": "Errores (%d)",
+}
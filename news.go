@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"strings"
+
+	"github.com/indes/flowerss-bot/config"
+	"github.com/indes/flowerss-bot/model"
+	"go.uber.org/zap"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// BroadcastNews sends the new contents of source to its subscribers, the update task of the source calls it
+func BroadcastNews(source *model.Source, subs []model.Subscribe, contents []model.Content) {
+	zap.S().Infow("broadcast news",
+		"source id", source.ID,
+		"title", source.Title,
+		"subscribers", len(subs),
+		"contents", len(contents),
+	)
+
+	for i := range contents {
+		content := &contents[i]
+		itemHistory.Record(source, content)
+		for j := range subs {
+			sub := &subs[j]
+			// watches are not subject to the filters, the alert replaces the push
+			if !subOptions.Get(sub.ID).Paused && alertWatches(source, sub, content) {
+				searchIndex.Add(source, sub, content)
+				continue
+			}
+			if !ShouldPushNews(source, sub, content) {
+				continue
+			}
+
+			msg, err := newsMessage(source, sub, content)
+			if err != nil {
+				zap.S().Warnf("render %s failed, err:%+v", content.RawLink, err)
+				continue
+			}
+			_, err = B.Send(&tb.Chat{ID: sub.UserID}, msg, &tb.SendOptions{
+				DisableWebPagePreview: config.DisableWebPagePreview,
+				ParseMode:             config.MessageMode,
+				DisableNotification:   sub.EnableNotification != 1,
+			})
+			if err == nil {
+				continue
+			}
+			zap.S().Warnf("send %s to %d failed, err:%+v", content.RawLink, sub.UserID, err)
+			if strings.Contains(err.Error(), "Forbidden") || strings.Contains(err.Error(), "chat not found") {
+				// the bot was blocked or removed from the chat
+				zap.S().Infof("%d unsubscribe [%d]%s %s", sub.UserID, source.ID, source.Title, source.Link)
+				if err := unsubSub(sub); err != nil {
+					zap.S().Errorf("unsubscribe %d from %d failed, err:%+v", sub.UserID, source.ID, err)
+				}
+			}
+		}
+	}
+}
+
+// ShouldPushNews reports whether content of source should be sent to sub now, the items of digest
+// subscriptions are queued. BroadcastNews calls it for every subscriber without a watch alert.
+func ShouldPushNews(source *model.Source, sub *model.Subscribe, content *model.Content) bool {
+	opt := subOptions.Get(sub.ID)
+	if opt.Paused {
+		return false
+	}
+	if !filterNews(sub, opt, content) {
+		return false
+	}
+	// pushed now or in a digest, the chat receives it
+	searchIndex.Add(source, sub, content)
+	if mode := deliveryModeOf(sub, opt); mode != deliveryImmediate {
+		digestQueue.Push(source, sub, content, mode)
+		return false
+	}
+	return true
+}
+
+// newsMessage renders content of source the way it is pushed to sub
+func newsMessage(source *model.Source, sub *model.Subscribe, content *model.Content) (string, error) {
+	tpldata := &config.TplData{
+		SourceTitle:     source.Title,
+		ContentTitle:    content.Title,
+		RawLink:         content.RawLink,
+		PreviewText:     previewText(content.Description),
+		TelegraphURL:    content.TelegraphUrl,
+		Tags:            sub.Tag,
+		EnableTelegraph: sub.EnableTelegraph == 1 && content.TelegraphUrl != "",
+	}
+	return tpldata.Render(config.MessageMode)
+}
+
+// previewText returns the first config.PreviewText characters of description, none when it is 0
+func previewText(description string) string {
+	if config.PreviewText <= 0 {
+		return ""
+	}
+	runes := []rune(description)
+	if len(runes) > config.PreviewText {
+		runes = runes[:config.PreviewText]
+	}
+	return string(runes)
+}
@@ -0,0 +1,289 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// opmlSyncInterval is how often the subscriptions are reconciled with the remote OPML
+const opmlSyncInterval = time.Hour
+
+// OPMLSync makes a remote OPML file the source of truth of the subscriptions of a chat
+type OPMLSync struct {
+	URL string `json:"url"`
+	// Remove unsubscribes the feeds missing from the file, otherwise they are only reported
+	Remove bool `json:"remove,omitempty"`
+	// ReportTo receives the diff reports, the chat /syncopml was run in
+	ReportTo int64  `json:"report_to"`
+	Lang     string `json:"lang"`
+	// SetBy is the user who ran /syncopml, checked again when ReportTo is another chat than the owner
+	SetBy int64 `json:"set_by,omitempty"`
+
+	LastAt    time.Time `json:"last_at"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// opmlSyncDiff is the outcome of one sync
+type opmlSyncDiff struct {
+	Added    []importItem
+	Retagged []importItem
+	Removed  []importItem
+	// Missing are the subscriptions not in the file, kept because Remove is off
+	Missing []importItem
+	Failed  []importItem
+	Invalid []importItem
+}
+
+func (d *opmlSyncDiff) empty() bool {
+	return len(d.Added)+len(d.Retagged)+len(d.Removed)+len(d.Failed) == 0
+}
+
+// opmlSyncLoop reconciles the due syncs, they are in the chat options so they survive restarts
+func opmlSyncLoop() {
+	ticker := time.NewTicker(backupCheckInterval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		for chatID, opt := range chatOptions.All() {
+			if opt.OPMLSync != nil && now.Sub(opt.OPMLSync.LastAt) >= opmlSyncInterval {
+				runOPMLSync(chatID, *opt.OPMLSync, false)
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// runOPMLSync reconciles the subscriptions of ownerID with the file of sync, the report is sent when
+// something changed, when the sync starts failing or when always is set
+func runOPMLSync(ownerID int64, sync OPMLSync, always bool) {
+	if sync.ReportTo != ownerID && !stillManages(sync.SetBy, ownerID) {
+		zap.S().Warnf("sync of %d skipped, %d no longer manages it", ownerID, sync.SetBy)
+		return
+	}
+	diff, err := syncOPML(ownerID, sync)
+
+	lastError := ""
+	if err != nil {
+		zap.S().Warnf("sync %s for %d failed, err:%+v", sync.URL, ownerID, err)
+		lastError = err.Error()
+	}
+	updateErr := chatOptions.Update(ownerID, func(opt *ChatOption) {
+		if opt.OPMLSync == nil || opt.OPMLSync.URL != sync.URL {
+			return
+		}
+		// a new sync, readers may still hold the old one
+		updated := *opt.OPMLSync
+		updated.LastAt = time.Now()
+		updated.LastError = lastError
+		opt.OPMLSync = &updated
+	})
+	if updateErr != nil {
+		zap.S().Errorf("save sync of %d failed, err:%+v", ownerID, updateErr)
+	}
+
+	chat := &tb.Chat{ID: sync.ReportTo}
+	if err != nil {
+		// failures are reported once, not every hour
+		if always || sync.LastError == "" {
+			_, _ = B.Send(chat, tr(sync.Lang, "syncopml_failed", sync.URL, err.Error()), &tb.SendOptions{DisableWebPagePreview: true})
+		}
+		return
+	}
+	if !always && diff.empty() {
+		return
+	}
+
+	opt := &tb.SendOptions{DisableWebPagePreview: true, ParseMode: tb.ModeHTML}
+	for _, part := range splitMessage(opmlSyncReport(sync, diff), 4096) {
+		_, _ = B.Send(chat, part, opt)
+	}
+}
+
+// syncOPML adds the feeds of the file ownerID is not subscribed to, sets the tags of the file on the
+// subscriptions and removes or reports the subscriptions not in the file
+func syncOPML(ownerID int64, sync OPMLSync) (*opmlSyncDiff, error) {
+	data, err := fetchImportFile(sync.URL)
+	if err != nil {
+		return nil, err
+	}
+	// only OPML is trusted: an error or login page read as a list of urls would remove every
+	// subscription, and an empty or unreadable file never removes anything
+	if !(opmlImporter{}).Sniff(data) {
+		return nil, fmt.Errorf("%s is not an OPML file", sync.URL)
+	}
+	items, err := parseOPML(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no feeds in %s", sync.URL)
+	}
+	preview, err := previewImport(ownerID, items)
+	if err != nil {
+		return nil, err
+	}
+	list, err := subSourceList(ownerID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &opmlSyncDiff{Invalid: preview.Invalid}
+	for _, item := range preview.New {
+		if err := importFeed(ownerID, item); err != nil {
+			zap.S().Warnf("sync %s for %d failed, err:%+v", item.URL, ownerID, err)
+			diff.Failed = append(diff.Failed, item)
+			continue
+		}
+		diff.Added = append(diff.Added, item)
+	}
+
+	subs := make(map[string]subSource, len(list))
+	for _, item := range list {
+		if key, err := normalizeFeedURL(item.source.Link); err == nil {
+			subs[key] = item
+		}
+	}
+	wanted := make(map[string]bool, len(items))
+	for _, item := range append(preview.Subscribed, preview.Update...) {
+		key, _ := normalizeFeedURL(item.URL)
+		wanted[key] = true
+
+		existing, ok := subs[key]
+		if !ok || len(item.Tags) == 0 || strings.Join(item.Tags, " ") == strings.Join(subTags(&existing.sub), " ") {
+			continue
+		}
+		sub := existing.sub
+		if err := sub.SetTag(item.Tags); err != nil {
+			zap.S().Warnf("set tags of %s for %d failed, err:%+v", item.URL, ownerID, err)
+			diff.Failed = append(diff.Failed, item)
+			continue
+		}
+		diff.Retagged = append(diff.Retagged, item)
+	}
+	for _, item := range preview.New {
+		key, _ := normalizeFeedURL(item.URL)
+		wanted[key] = true
+	}
+
+	for key, existing := range subs {
+		if wanted[key] {
+			continue
+		}
+		item := importItem{Title: existing.source.Title, URL: existing.source.Link}
+		if !sync.Remove {
+			diff.Missing = append(diff.Missing, item)
+			continue
+		}
+		if err := unsubSubID(ownerID, existing.sub.ID); err != nil {
+			zap.S().Warnf("sync unsubscribe %s for %d failed, err:%+v", item.URL, ownerID, err)
+			diff.Failed = append(diff.Failed, item)
+			continue
+		}
+		zap.S().Infof("%d unsubscribe [%d]%s %s", ownerID, existing.source.ID, existing.source.Title, existing.source.Link)
+		diff.Removed = append(diff.Removed, item)
+	}
+	return diff, nil
+}
+
+func opmlSyncReport(sync OPMLSync, diff *opmlSyncDiff) string {
+	report := tr(sync.Lang, "syncopml_report", html.EscapeString(sync.URL), len(diff.Added), len(diff.Retagged), len(diff.Removed), len(diff.Failed))
+	sections := []struct {
+		key   string
+		items []importItem
+	}{
+		{"syncopml_report_added", diff.Added},
+		{"syncopml_report_retagged", diff.Retagged},
+		{"syncopml_report_removed", diff.Removed},
+		{"syncopml_report_missing", diff.Missing},
+		{"syncopml_report_failed", diff.Failed},
+		{"syncopml_report_invalid", diff.Invalid},
+	}
+	for _, section := range sections {
+		if len(section.items) != 0 {
+			report += "\n\n" + tr(sync.Lang, section.key) + formatImportItems(section.items)
+		}
+	}
+	return report
+}
+
+func opmlSyncMode(lang string, sync *OPMLSync) string {
+	if sync.Remove {
+		return tr(lang, "syncopml_mode_remove")
+	}
+	return tr(lang, "syncopml_mode_keep")
+}
+
+func syncOPMLCmdCtr(m *tb.Message) {
+	lang := msgLang(m)
+	target := authTarget(m, lang, GetMentionFromMessage(m))
+	if target == nil {
+		return
+	}
+
+	args := commandArgs(m)
+	if len(args) == 0 {
+		sync := chatOptions.Get(target.ID).OPMLSync
+		if sync == nil {
+			_, _ = B.Send(m.Chat, tr(lang, "syncopml_usage"))
+			return
+		}
+		status := tr(lang, "syncopml_status", sync.URL, opmlSyncMode(lang, sync), sync.LastAt.Format("2006-01-02 15:04"))
+		if sync.LastError != "" {
+			status += "\n" + tr(lang, "syncopml_last_error", sync.LastError)
+		}
+		_, _ = B.Send(m.Chat, status, &tb.SendOptions{DisableWebPagePreview: true})
+		return
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "off":
+		err := chatOptions.Update(target.ID, func(opt *ChatOption) { opt.OPMLSync = nil })
+		if err != nil {
+			zap.S().Errorf("disable sync of %d failed, err:%+v", target.ID, err)
+			_, _ = B.Send(m.Chat, tr(lang, "error"))
+			return
+		}
+		_, _ = B.Send(m.Chat, tr(lang, "syncopml_disabled"))
+		return
+	case "now":
+		sync := chatOptions.Get(target.ID).OPMLSync
+		if sync == nil {
+			_, _ = B.Send(m.Chat, tr(lang, "syncopml_usage"))
+			return
+		}
+		go runOPMLSync(target.ID, *sync, true)
+		return
+	}
+
+	sync := OPMLSync{URL: args[0], ReportTo: m.Chat.ID, Lang: lang}
+	if m.Sender != nil {
+		sync.SetBy = int64(m.Sender.ID)
+	}
+	if _, err := normalizeFeedURL(sync.URL); err != nil {
+		_, _ = B.Send(m.Chat, tr(lang, "syncopml_usage"))
+		return
+	}
+	if len(args) > 1 {
+		if strings.ToLower(args[1]) != "remove" {
+			_, _ = B.Send(m.Chat, tr(lang, "syncopml_usage"))
+			return
+		}
+		sync.Remove = true
+	}
+
+	if err := chatOptions.Update(target.ID, func(opt *ChatOption) { opt.OPMLSync = &sync }); err != nil {
+		zap.S().Errorf("enable sync of %d failed, err:%+v", target.ID, err)
+		_, _ = B.Send(m.Chat, tr(lang, "error"))
+		return
+	}
+	_, _ = B.Send(m.Chat, tr(lang, "syncopml_enabled", sync.URL, opmlSyncMode(lang, &sync)), &tb.SendOptions{DisableWebPagePreview: true})
+
+	// the first sync runs right away
+	go runOPMLSync(target.ID, sync, true)
+}
@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// maxImportTags is the number of tags sub.SetTag accepts
+	maxImportTags = 3
+	// maxImportFileSize limits the size of the files downloaded for an import
+	maxImportFileSize = 5 << 20
+)
+
+// opmlDocument is an OPML file keeping its folders, GetFlattenOutlines drops them
+type opmlDocument struct {
+	XMLName     xml.Name      `xml:"opml"`
+	Version     string        `xml:"version,attr"`
+	Title       string        `xml:"head>title"`
+	DateCreated string        `xml:"head>dateCreated,omitempty"`
+	Outlines    []opmlOutline `xml:"body>outline"`
+}
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"`
+	Category string        `xml:"category,attr,omitempty"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+// fetchImportFile downloads the file of an import, files over maxImportFileSize are refused.
+// The errors leave link out, the urls of Telegram files hold the bot token.
+func fetchImportFile(link string) ([]byte, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(link)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			return nil, fmt.Errorf("download file: %v", urlErr.Err)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download file: %s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxImportFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("download file: %v", err)
+	}
+	if len(data) > maxImportFileSize {
+		return nil, fmt.Errorf("file is larger than %d MB", maxImportFileSize>>20)
+	}
+	return data, nil
+}
+
+// parseOPML returns the feeds of an OPML file, tagged with the folders they are in
+func parseOPML(data []byte) ([]importItem, error) {
+	var doc opmlDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return walkOutlines(doc.Outlines, nil), nil
+}
+
+func walkOutlines(outlines []opmlOutline, folders []string) []importItem {
+	var items []importItem
+	for _, outline := range outlines {
+		name := outline.Text
+		if name == "" {
+			name = outline.Title
+		}
+
+		if outline.XMLURL == "" {
+			// a folder
+			items = append(items, walkOutlines(outline.Outlines, append(folders[:len(folders):len(folders)], name))...)
+			continue
+		}
+
+		tags := folders
+		if len(tags) == 0 && outline.Category != "" {
+			// OPML 2.0 categories, "/Tech/Go,News"
+			tags = strings.FieldsFunc(outline.Category, func(r rune) bool { return r == '/' || r == ',' })
+		}
+		items = append(items, importItem{Title: name, URL: outline.XMLURL, Tags: importTags(tags)})
+	}
+	return items
+}
+
+// importTags turns folder names into tags sub.SetTag accepts
+func importTags(names []string) []string {
+	var tags []string
+	for _, name := range names {
+		tag := strings.Join(strings.Fields(strings.TrimLeft(name, "#")), "_")
+		if tag == "" {
+			continue
+		}
+		if tags = append(tags, tag); len(tags) == maxImportTags {
+			break
+		}
+	}
+	return tags
+}
+
+// toTaggedOPML writes list as an OPML file with one top level folder per tag, subscriptions with
+// several tags are in each of their folders
+func toTaggedOPML(list []subSource) (string, error) {
+	doc := opmlDocument{
+		Version:     "2.0",
+		Title:       "flowerss subscriptions",
+		DateCreated: time.Now().Format(time.RFC1123Z),
+	}
+	for _, item := range list {
+		outline := opmlOutline{
+			Text:   item.source.Title,
+			Title:  item.source.Title,
+			Type:   "rss",
+			XMLURL: item.source.Link,
+		}
+		tags := subTags(&item.sub)
+		if len(tags) == 0 {
+			doc.Outlines = append(doc.Outlines, outline)
+			continue
+		}
+		for _, tag := range tags {
+			doc.Outlines = insertOutline(doc.Outlines, tag, outline)
+		}
+	}
+
+	b, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(b), nil
+}
+
+// insertOutline adds outline to the top level folder named folder, creating it when missing
+func insertOutline(outlines []opmlOutline, folder string, outline opmlOutline) []opmlOutline {
+	for i := range outlines {
+		if outlines[i].XMLURL == "" && outlines[i].Text == folder {
+			outlines[i].Outlines = append(outlines[i].Outlines, outline)
+			return outlines
+		}
+	}
+	return append(outlines, opmlOutline{Text: folder, Title: folder, Outlines: []opmlOutline{outline}})
+}
@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/indes/flowerss-bot/model"
+)
+
+func TestInsertOutline(t *testing.T) {
+	goBlog := opmlOutline{Text: "Go", XMLURL: "https://go.dev/blog/feed.atom"}
+	news := opmlOutline{Text: "News", XMLURL: "https://example.com/rss"}
+
+	outlines := insertOutline(nil, "Tech", goBlog)
+	outlines = insertOutline(outlines, "Daily", news)
+	outlines = insertOutline(outlines, "Tech", news)
+	want := []opmlOutline{
+		{Text: "Tech", Title: "Tech", Outlines: []opmlOutline{goBlog, news}},
+		{Text: "Daily", Title: "Daily", Outlines: []opmlOutline{news}},
+	}
+	if !reflect.DeepEqual(outlines, want) {
+		t.Errorf("insertOutline() = %+v, want %+v", outlines, want)
+	}
+}
+
+func TestTaggedOPMLRoundTrip(t *testing.T) {
+	list := []subSource{
+		{sub: model.Subscribe{Tag: "#Tech #Go"}, source: model.Source{Title: "Go", Link: "https://go.dev/blog/feed.atom"}},
+		{source: model.Source{Title: "News", Link: "https://example.com/rss"}},
+	}
+	data, err := toTaggedOPML(list)
+	if err != nil {
+		t.Fatalf("toTaggedOPML() error = %v", err)
+	}
+
+	items, err := parseOPML([]byte(data))
+	if err != nil {
+		t.Fatalf("parseOPML() error = %v", err)
+	}
+	items, duplicates := mergeDuplicateItems(items)
+	want := []importItem{
+		{Title: "Go", URL: "https://go.dev/blog/feed.atom", Tags: []string{"Tech", "Go"}},
+		{Title: "News", URL: "https://example.com/rss"},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("imported %+v, want %+v", items, want)
+	}
+	if len(duplicates) != 0 {
+		t.Errorf("imported duplicates %+v", duplicates)
+	}
+}
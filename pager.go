@@ -0,0 +1,250 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/indes/flowerss-bot/model"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const pageSize = 10
+
+const (
+	pageKindSet    = "set"
+	pageKindUnsub  = "unsub"
+	pageKindList   = "list"
+	pageKindSearch = "search"
+)
+
+// pager is the position of a paged subscription list or search, it is stored behind the page buttons
+type pager struct {
+	Kind  string
+	Owner int64
+	Page  int
+	Tag   string
+	Query string
+}
+
+// data stores the pager moved to page for a button sent to chatID
+func (p pager) data(chatID int64, page int) string {
+	return newCallback(chatID, callbackPayload{Kind: p.Kind, Owner: p.Owner, Page: page, Tag: p.Tag, Arg: p.Query})
+}
+
+// slice clamps the page into range and returns the bounds of its items and the page count
+func (p *pager) slice(total int) (start, end, pages int) {
+	pages = (total + pageSize - 1) / pageSize
+	if pages == 0 {
+		pages = 1
+	}
+	if p.Page >= pages {
+		p.Page = pages - 1
+	}
+	if p.Page < 0 {
+		p.Page = 0
+	}
+
+	start = p.Page * pageSize
+	end = start + pageSize
+	if end > total {
+		end = total
+	}
+	return start, end, pages
+}
+
+// navRow returns the prev / page N of M / next buttons, nil when everything fits in one page
+func (p pager) navRow(lang string, chatID int64, pages int) []tb.InlineButton {
+	if pages <= 1 {
+		return nil
+	}
+
+	row := []tb.InlineButton{}
+	if p.Page > 0 {
+		row = append(row, tb.InlineButton{Unique: "page_btn", Text: "«", Data: p.data(chatID, p.Page-1)})
+	}
+	row = append(row, tb.InlineButton{
+		Unique: "page_btn",
+		Text:   tr(lang, "page_of", p.Page+1, pages),
+		Data:   p.data(chatID, p.Page),
+	})
+	if p.Page < pages-1 {
+		row = append(row, tb.InlineButton{Unique: "page_btn", Text: "»", Data: p.data(chatID, p.Page+1)})
+	}
+	return row
+}
+
+type subSource struct {
+	sub    model.Subscribe
+	source model.Source
+}
+
+// subSourceList returns the subscriptions of ownerID sorted by id, only those tagged tag when tag is set
+func subSourceList(ownerID int64, tag string) ([]subSource, error) {
+	user, err := model.FindOrCreateUserByTelegramID(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	subSourceMap, err := user.GetSubSourceMap()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]subSource, 0, len(subSourceMap))
+	for sub, source := range subSourceMap {
+		if tag != "" && !hasTag(&sub, tag) {
+			continue
+		}
+		list = append(list, subSource{sub: sub, source: source})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].sub.ID < list[j].sub.ID })
+	return list, nil
+}
+
+func hasTag(sub *model.Subscribe, tag string) bool {
+	for _, t := range subTags(sub) {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// tagArg returns the first #tag argument of the command
+func tagArg(m *tb.Message) string {
+	for _, arg := range strings.Fields(m.Payload) {
+		if strings.HasPrefix(arg, "#") && len(arg) > 1 {
+			return arg[1:]
+		}
+	}
+	return ""
+}
+
+// renderPage returns the text and keyboard of the page p sent to chatID, channel is the owner chat when it is not the current one
+func renderPage(lang string, chatID int64, p *pager, channel *tb.Chat) (string, [][]tb.InlineButton, error) {
+	if p.Kind == pageKindSearch {
+		return renderSearchPage(lang, chatID, p)
+	}
+
+	list, err := subSourceList(p.Owner, p.Tag)
+	if err != nil {
+		return "", nil, err
+	}
+	start, end, pages := p.slice(len(list))
+
+	var text string
+	var keys [][]tb.InlineButton
+	switch p.Kind {
+	case pageKindSet:
+		text = tr(lang, "set_choose_feed")
+		for _, item := range list[start:end] {
+			keys = append(keys, []tb.InlineButton{
+				tb.InlineButton{
+					Unique: "set_feed_item_btn",
+					Text:   fmt.Sprintf("[%d] %s", item.source.ID, item.source.Title),
+					Data:   newCallback(chatID, callbackPayload{Owner: p.Owner, SourceID: item.source.ID}),
+				},
+			})
+		}
+	case pageKindUnsub:
+		text = tr(lang, "unsub_choose_feed")
+		for _, item := range list[start:end] {
+			keys = append(keys, []tb.InlineButton{
+				tb.InlineButton{
+					Unique: "unsub_feed_item_btn",
+					Text:   fmt.Sprintf("[%d] %s", item.sub.SourceID, item.source.Title),
+					Data: newCallback(chatID, callbackPayload{
+						Owner: item.sub.UserID, SubID: item.sub.ID, SourceID: item.source.ID,
+					}),
+				},
+			})
+		}
+	case pageKindList:
+		switch {
+		case channel != nil && len(list) == 0:
+			text = tr(lang, "list_channel_empty", channel.Title, channel.Username)
+		case channel != nil:
+			text = tr(lang, "list_channel_title", channel.Title, channel.Username)
+		case len(list) == 0:
+			text = tr(lang, "sub_list_empty")
+		default:
+			text = tr(lang, "list_title")
+		}
+		for _, item := range list[start:end] {
+			text += fmt.Sprintf("[[%d]] [%s](%s)", item.sub.ID, item.source.Title, item.source.Link) + listFilteredNote(lang, item.sub.ID) + "\n"
+		}
+	default:
+		return "", nil, fmt.Errorf("unknown page kind %q", p.Kind)
+	}
+
+	if p.Tag != "" {
+		text = "#" + p.Tag + "\n" + text
+	}
+	if row := p.navRow(lang, chatID, pages); row != nil {
+		keys = append(keys, row)
+	}
+	return text, keys, nil
+}
+
+// sendPage sends the first page of kind for the subscriptions of owner
+func sendPage(m *tb.Message, kind string, owner int64, channel *tb.Chat) {
+	sendPageTo(msgLang(m), m.Chat, &pager{Kind: kind, Owner: owner, Tag: tagArg(m)}, channel)
+}
+
+// sendPageTo sends the page p to chat
+func sendPageTo(lang string, chat *tb.Chat, p *pager, channel *tb.Chat) {
+	text, keys, err := renderPage(lang, chat.ID, p, channel)
+	if err != nil {
+		_, _ = B.Send(chat, tr(lang, "list_error", 2))
+		return
+	}
+
+	_, _ = B.Send(chat, text, &tb.SendOptions{
+		DisableWebPagePreview: true,
+		ParseMode:             pageParseMode(p.Kind),
+	}, &tb.ReplyMarkup{
+		InlineKeyboard: keys,
+	})
+}
+
+func pageParseMode(kind string) tb.ParseMode {
+	switch kind {
+	case pageKindList:
+		return tb.ModeMarkdown
+	case pageKindSearch:
+		return tb.ModeHTML
+	}
+	return tb.ModeDefault
+}
+
+func pageBtnCtr(c *tb.Callback) {
+	lang := cbLang(c)
+	payload, ok := loadCallback(c)
+	if !ok || !callbackAuth(c, payload.Owner) {
+		return
+	}
+
+	var channel *tb.Chat
+	if payload.Owner != c.Message.Chat.ID {
+		var err error
+		channel, err = B.ChatByID(fmt.Sprintf("%d", payload.Owner))
+		if err != nil {
+			return
+		}
+	}
+
+	p := pager{Kind: payload.Kind, Owner: payload.Owner, Page: payload.Page, Tag: payload.Tag, Query: payload.Arg}
+	text, keys, err := renderPage(lang, c.Message.Chat.ID, &p, channel)
+	if err != nil {
+		_ = B.Respond(c, &tb.CallbackResponse{Text: tr(lang, "error")})
+		return
+	}
+	_ = B.Respond(c)
+	_, _ = B.Edit(c.Message, text, &tb.SendOptions{
+		DisableWebPagePreview: true,
+		ParseMode:             pageParseMode(p.Kind),
+	}, &tb.ReplyMarkup{
+		InlineKeyboard: keys,
+	})
+}
@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// flushInterval is how often the stores written for every pushed item are saved
+const flushInterval = time.Minute
+
+// Persister saves bot side state that has no table in model, such as chat languages.
+// The default implementation writes one JSON file per key, a database backed
+// implementation can be installed with SetPersister.
+type Persister interface {
+	Load(key string, v interface{}) error
+	Save(key string, v interface{}) error
+}
+
+var persister Persister = NewFilePersister("data")
+
+// SetPersister replaces the persister, must be called before the bot starts
+func SetPersister(p Persister) {
+	persister = p
+}
+
+// FilePersister stores every key as dir/key.json
+type FilePersister struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFilePersister returns a persister writing into dir
+func NewFilePersister(dir string) *FilePersister {
+	return &FilePersister{dir: dir}
+}
+
+// Load decodes key into v, a key that was never saved leaves v untouched
+func (p *FilePersister) Load(key string, v interface{}) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	data, err := ioutil.ReadFile(p.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Save encodes v into key, replacing the file atomically
+func (p *FilePersister) Save(key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := os.MkdirAll(p.dir, 0755); err != nil {
+		return err
+	}
+	tmp := p.path(key) + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p.path(key))
+}
+
+func (p *FilePersister) path(key string) string {
+	return filepath.Join(p.dir, key+".json")
+}
+
+// flushLoop saves the stores changed since the last flush every flushInterval
+func flushLoop() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		Flush()
+	}
+}
+
+// Flush saves the items and counters recorded since the last flush, call it before the bot exits
+func Flush() {
+	chatOptions.flush()
+	subOptions.flush()
+	digestQueue.flush()
+	itemHistory.flush()
+	searchIndex.flush()
+	callbacks.flush()
+}
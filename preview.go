@@ -0,0 +1,190 @@
+package bot
+
+import (
+	"bytes"
+	"html"
+	"sort"
+	"time"
+
+	"github.com/indes/flowerss-bot/config"
+	"github.com/indes/flowerss-bot/model"
+	"github.com/mmcdole/gofeed"
+	"go.uber.org/zap"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// previewItems is the number of items shown by /preview
+const previewItems = 3
+
+// fetchFeed downloads and parses the feed at url without storing it
+func fetchFeed(url string) (*gofeed.Feed, error) {
+	data, err := fetchImportFile(url)
+	if err != nil {
+		return nil, err
+	}
+	return gofeed.NewParser().Parse(bytes.NewReader(data))
+}
+
+// itemTime returns when item was published, or updated when the feed has no publication dates
+func itemTime(item *gofeed.Item) *time.Time {
+	if item.PublishedParsed != nil {
+		return item.PublishedParsed
+	}
+	return item.UpdatedParsed
+}
+
+// feedFrequency estimates the time between two items of feed, 0 when the items are not dated
+func feedFrequency(feed *gofeed.Feed) time.Duration {
+	var times []time.Time
+	for _, item := range feed.Items {
+		if t := itemTime(item); t != nil {
+			times = append(times, *t)
+		}
+	}
+	if len(times) < 2 {
+		return 0
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times[len(times)-1].Sub(times[0]) / time.Duration(len(times)-1)
+}
+
+// newestItems returns the n newest items of feed, newest first, undated items keep the order of the feed
+func newestItems(feed *gofeed.Feed, n int) []*gofeed.Item {
+	items := append([]*gofeed.Item(nil), feed.Items...)
+	sort.SliceStable(items, func(i, j int) bool {
+		ti, tj := itemTime(items[i]), itemTime(items[j])
+		return ti != nil && (tj == nil || ti.After(*tj))
+	})
+	if len(items) > n {
+		items = items[:n]
+	}
+	return items
+}
+
+func formatFrequency(lang string, d time.Duration) string {
+	switch {
+	case d <= 0:
+		return tr(lang, "preview_frequency_unknown")
+	case d < time.Hour:
+		return tr(lang, "preview_frequency_minutes", int(d.Minutes())+1)
+	case d < 48*time.Hour:
+		return tr(lang, "preview_frequency_hours", int(d.Hours()))
+	default:
+		return tr(lang, "preview_frequency_days", int(d.Hours()/24))
+	}
+}
+
+// renderFeedPreview describes feed, its newest items are sent after it by previewMessages
+func renderFeedPreview(lang string, title string, feed *gofeed.Feed) string {
+	feedType := feed.FeedType
+	if feed.FeedVersion != "" {
+		feedType += " " + feed.FeedVersion
+	}
+	msg := tr(lang, "preview_title", html.EscapeString(title), html.EscapeString(feedType), len(feed.Items), formatFrequency(lang, feedFrequency(feed)))
+	if len(feed.Items) == 0 {
+		msg += "\n\n" + tr(lang, "preview_no_items")
+	}
+	return msg
+}
+
+// previewMessages renders the newest items of feed the way they are pushed, oldest first
+func previewMessages(title string, url string, feed *gofeed.Feed) []string {
+	source := &model.Source{Title: title, Link: url}
+	items := newestItems(feed, previewItems)
+	var msgs []string
+	for i := len(items) - 1; i >= 0; i-- {
+		description := items[i].Description
+		if description == "" {
+			description = items[i].Content
+		}
+		msg, err := newsMessage(source, &model.Subscribe{}, &model.Content{
+			Title:       items[i].Title,
+			RawLink:     items[i].Link,
+			Description: description,
+		})
+		if err != nil {
+			zap.S().Warnf("render %s failed, err:%+v", items[i].Link, err)
+			continue
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func previewCmdCtr(m *tb.Message) {
+	lang := msgLang(m)
+	args := commandArgs(m)
+	if len(args) == 0 || !CheckURL(args[0]) {
+		_, _ = B.Send(m.Chat, tr(lang, "preview_usage"))
+		return
+	}
+	target := authTarget(m, lang, GetMentionFromMessage(m))
+	if target == nil {
+		return
+	}
+
+	url := args[0]
+	if candidates := discoverFeeds(url); len(candidates) > 0 {
+		// a web page, the first feed it announces is shown
+		url = candidates[0].URL
+	}
+	feed, err := fetchFeed(url)
+	if err != nil {
+		zap.S().Warnf("preview %s failed, err:%+v", url, err)
+		_, _ = B.Send(m.Chat, tr(lang, "preview_failed", url, err.Error()), &tb.SendOptions{DisableWebPagePreview: true})
+		return
+	}
+
+	title := feed.Title
+	if title == "" {
+		title = url
+	}
+	_, _ = B.Send(m.Chat, renderFeedPreview(lang, title, feed), &tb.SendOptions{
+		DisableWebPagePreview: true,
+		ParseMode:             tb.ModeHTML,
+	}, &tb.ReplyMarkup{
+		InlineKeyboard: [][]tb.InlineButton{
+			[]tb.InlineButton{
+				tb.InlineButton{
+					Unique: "preview_sub_btn",
+					Text:   tr(lang, "btn_preview_sub"),
+					Data:   newCallback(m.Chat.ID, callbackPayload{Owner: target.ID, Kind: "preview", Arg: url}),
+				},
+			},
+		},
+	})
+	for _, msg := range previewMessages(title, url, feed) {
+		_, _ = B.Send(m.Chat, msg, &tb.SendOptions{
+			DisableWebPagePreview: config.DisableWebPagePreview,
+			ParseMode:             config.MessageMode,
+		})
+	}
+}
+
+func previewSubBtnCtr(c *tb.Callback) {
+	payload, ok := loadCallback(c)
+	if !ok || payload.Kind != "preview" || !callbackAuth(c, payload.Owner) {
+		return
+	}
+	_ = B.Respond(c)
+	_, _ = B.EditReplyMarkup(c.Message, nil)
+
+	backfill := chatOptions.Get(payload.Owner).Backfill
+	if payload.Owner == c.Message.Chat.ID {
+		subscribeWithBackfill(payload.Owner, payload.Arg, backfill, func() { registFeed(c.Message.Chat, payload.Arg) })
+		return
+	}
+
+	lang := cbLang(c)
+	var err error
+	subscribeWithBackfill(payload.Owner, payload.Arg, backfill, func() {
+		err = importFeed(payload.Owner, importItem{URL: payload.Arg})
+	})
+	if err != nil {
+		zap.S().Warnf("subscribe %d to %s failed, err:%+v", payload.Owner, payload.Arg, err)
+		_, _ = B.Send(c.Message.Chat, tr(lang, "sub_discover_failed", payload.Arg), &tb.SendOptions{DisableWebPagePreview: true})
+		return
+	}
+	_, _ = B.Send(c.Message.Chat, tr(lang, "sub_discover_success", payload.Arg), &tb.SendOptions{DisableWebPagePreview: true})
+}
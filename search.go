@@ -0,0 +1,308 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/indes/flowerss-bot/model"
+	"go.uber.org/zap"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	searchIndexKey = "search_index"
+	// maxSearchItems is the number of delivered items kept per chat
+	maxSearchItems = 1000
+	// maxSearchText is the number of characters of the content of an item that are indexed
+	maxSearchText = 300
+)
+
+var (
+	htmlTagRegexp = regexp.MustCompile(`<[^>]*>`)
+	// accentFolder makes "canción" match "cancion"
+	accentFolder = strings.NewReplacer(
+		"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a",
+		"é", "e", "è", "e", "ê", "e", "ë", "e",
+		"í", "i", "ì", "i", "î", "i", "ï", "i",
+		"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o",
+		"ú", "u", "ù", "u", "û", "u", "ü", "u",
+		"ñ", "n", "ç", "c",
+	)
+)
+
+// deliveredItem is an item sent to a chat, by a push or a digest
+type deliveredItem struct {
+	ID          uint64    `json:"id"`
+	SubID       uint      `json:"sub_id"`
+	SourceTitle string    `json:"source_title"`
+	Title       string    `json:"title"`
+	Link        string    `json:"link"`
+	Text        string    `json:"text,omitempty"`
+	DeliveredAt time.Time `json:"delivered_at"`
+}
+
+// searchIndexStore keeps the latest items delivered to every chat with an inverted index of their words
+type searchIndexStore struct {
+	once  sync.Once
+	mu    sync.Mutex
+	items map[int64][]deliveredItem
+	// words maps the words of the items of a chat to their ids, it is rebuilt on load
+	words map[int64]map[string][]uint64
+	// dirty is set by Add, flush saves the items
+	dirty bool
+}
+
+var searchIndex = &searchIndexStore{}
+
+func (s *searchIndexStore) load() {
+	s.once.Do(func() {
+		s.items = make(map[int64][]deliveredItem)
+		s.words = make(map[int64]map[string][]uint64)
+		if err := persister.Load(searchIndexKey, &s.items); err != nil {
+			zap.S().Errorf("load search index failed, err:%+v", err)
+		}
+		for chatID, items := range s.items {
+			for _, item := range items {
+				s.index(chatID, item)
+			}
+		}
+	})
+}
+
+// index must be called with s.mu held
+func (s *searchIndexStore) index(chatID int64, item deliveredItem) {
+	words := s.words[chatID]
+	if words == nil {
+		words = make(map[string][]uint64)
+		s.words[chatID] = words
+	}
+	seen := make(map[string]bool)
+	for _, word := range searchWords(item.Title + " " + item.Text) {
+		if !seen[word] {
+			seen[word] = true
+			words[word] = append(words[word], item.ID)
+		}
+	}
+}
+
+// Add indexes content delivered to the chat of sub, it is saved by the next flush
+func (s *searchIndexStore) Add(source *model.Source, sub *model.Subscribe, content *model.Content) {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := s.items[sub.UserID]
+	var id uint64 = 1
+	if len(items) > 0 {
+		id = items[len(items)-1].ID + 1
+	}
+	text := []rune(strings.Join(strings.Fields(html.UnescapeString(htmlTagRegexp.ReplaceAllString(content.Description, " "))), " "))
+	if len(text) > maxSearchText {
+		text = text[:maxSearchText]
+	}
+	item := deliveredItem{
+		ID:          id,
+		SubID:       sub.ID,
+		SourceTitle: source.Title,
+		Title:       content.Title,
+		Link:        content.RawLink,
+		Text:        string(text),
+		DeliveredAt: time.Now(),
+	}
+	items = append(items, item)
+	s.index(sub.UserID, item)
+
+	if len(items) > maxSearchItems {
+		items = items[len(items)-maxSearchItems:]
+		// Search skips the ids of dropped items, the index is rebuilt every maxSearchItems dropped items
+		if items[0].ID%maxSearchItems == 0 {
+			delete(s.words, sub.UserID)
+			for _, item := range items {
+				s.index(sub.UserID, item)
+			}
+		}
+	}
+	s.items[sub.UserID] = items
+	s.dirty = true
+}
+
+func (s *searchIndexStore) flush() {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return
+	}
+	if err := persister.Save(searchIndexKey, s.items); err != nil {
+		zap.S().Errorf("save search index failed, err:%+v", err)
+		return
+	}
+	s.dirty = false
+}
+
+// searchQuery is a parsed /search query
+type searchQuery struct {
+	Words []string
+	Since time.Time
+	Until time.Time
+	// SubIDs restricts the results to some subscriptions when set, for tag filters
+	SubIDs map[uint]bool
+}
+
+// Search returns the items delivered to chatID matching q, newest first. Every word of q must be the
+// start of a word of the title or the content of an item.
+func (s *searchIndexStore) Search(chatID int64, q searchQuery) []deliveredItem {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := s.items[chatID]
+	var ids map[uint64]bool
+	for _, word := range q.Words {
+		matches := make(map[uint64]bool)
+		for indexed, wordIDs := range s.words[chatID] {
+			if !strings.HasPrefix(indexed, word) {
+				continue
+			}
+			for _, id := range wordIDs {
+				if ids == nil || ids[id] {
+					matches[id] = true
+				}
+			}
+		}
+		ids = matches
+	}
+
+	var results []deliveredItem
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
+		switch {
+		case ids != nil && !ids[item.ID]:
+		case q.SubIDs != nil && !q.SubIDs[item.SubID]:
+		case !q.Since.IsZero() && item.DeliveredAt.Before(q.Since):
+		case !q.Until.IsZero() && !item.DeliveredAt.Before(q.Until):
+		default:
+			results = append(results, item)
+		}
+	}
+	return results
+}
+
+// searchWords splits text into lower case words without accents
+func searchWords(text string) []string {
+	text = accentFolder.Replace(strings.ToLower(text))
+	return strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsNumber(r) })
+}
+
+// parseSearchQuery reads the words and the since: / until: dates of query, dates are days in loc.
+// desde: and hasta: are accepted too.
+func parseSearchQuery(query string, loc *time.Location) (searchQuery, error) {
+	var q searchQuery
+	for _, field := range strings.Fields(query) {
+		key, value := "", field
+		if i := strings.Index(field, ":"); i > 0 {
+			key, value = strings.ToLower(field[:i]), field[i+1:]
+		}
+		switch key {
+		case "since", "desde", "until", "hasta":
+			day, err := time.ParseInLocation("2006-01-02", value, loc)
+			if err != nil {
+				return q, err
+			}
+			if key == "since" || key == "desde" {
+				q.Since = day
+			} else {
+				// the whole day is included
+				q.Until = day.AddDate(0, 0, 1)
+			}
+		default:
+			q.Words = append(q.Words, searchWords(field)...)
+		}
+	}
+	return q, nil
+}
+
+// renderSearchPage returns the page p of the results of the search p.Query among the items delivered to p.Owner
+func renderSearchPage(lang string, chatID int64, p *pager) (string, [][]tb.InlineButton, error) {
+	q, err := parseSearchQuery(p.Query, chatLocation(chatOptions.Get(p.Owner)))
+	if err != nil {
+		return "", nil, err
+	}
+	if p.Tag != "" {
+		list, err := subSourceList(p.Owner, p.Tag)
+		if err != nil {
+			return "", nil, err
+		}
+		q.SubIDs = make(map[uint]bool, len(list))
+		for _, item := range list {
+			q.SubIDs[item.sub.ID] = true
+		}
+	}
+
+	shown := p.Query
+	if p.Tag != "" {
+		shown = strings.TrimSpace("#" + p.Tag + " " + shown)
+	}
+	results := searchIndex.Search(p.Owner, q)
+	start, end, pages := p.slice(len(results))
+	if len(results) == 0 {
+		return tr(lang, "search_no_results", html.EscapeString(shown)), nil, nil
+	}
+
+	loc := chatLocation(chatOptions.Get(p.Owner))
+	text := tr(lang, "search_results", len(results), html.EscapeString(shown))
+	for i, item := range results[start:end] {
+		text += fmt.Sprintf("\n\n%d. <a href=\"%s\">%s</a>\n%s · %s", start+i+1, html.EscapeString(item.Link), html.EscapeString(item.Title),
+			html.EscapeString(item.SourceTitle), item.DeliveredAt.In(loc).Format("2006-01-02 15:04"))
+	}
+
+	var keys [][]tb.InlineButton
+	if row := p.navRow(lang, chatID, pages); row != nil {
+		keys = append(keys, row)
+	}
+	return text, keys, nil
+}
+
+func searchCmdCtr(m *tb.Message) {
+	lang := msgLang(m)
+	target := authTarget(m, lang, GetMentionFromMessage(m))
+	if target == nil {
+		return
+	}
+
+	var words []string
+	for _, arg := range commandArgs(m) {
+		if !strings.HasPrefix(arg, "#") {
+			words = append(words, arg)
+		}
+	}
+	p := &pager{Kind: pageKindSearch, Owner: target.ID, Tag: tagArg(m), Query: strings.Join(words, " ")}
+	if p.Query == "" && p.Tag == "" {
+		_, _ = B.Send(m.Chat, tr(lang, "search_usage"))
+		return
+	}
+	if _, err := parseSearchQuery(p.Query, time.UTC); err != nil {
+		_, _ = B.Send(m.Chat, tr(lang, "search_usage"))
+		return
+	}
+
+	text, keys, err := renderSearchPage(lang, m.Chat.ID, p)
+	if err != nil {
+		zap.S().Errorf("search of %d failed, err:%+v", target.ID, err)
+		_, _ = B.Send(m.Chat, tr(lang, "error"))
+		return
+	}
+	_, _ = B.Send(m.Chat, text, &tb.SendOptions{
+		DisableWebPagePreview: true,
+		ParseMode:             pageParseMode(p.Kind),
+	}, &tb.ReplyMarkup{
+		InlineKeyboard: keys,
+	})
+}
@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSearchWords(t *testing.T) {
+	got := searchWords("Él publicó: Go-1.16, ¡por fin!")
+	want := []string{"el", "publico", "go", "1", "16", "por", "fin"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("searchWords() = %q, want %q", got, want)
+	}
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	loc := time.FixedZone("UTC-3", -3*60*60)
+	tests := []struct {
+		query   string
+		want    searchQuery
+		wantErr bool
+	}{
+		{query: "Golang  releases", want: searchQuery{Words: []string{"golang", "releases"}}},
+		{
+			query: "go since:2021-03-01 until:2021-03-10",
+			want: searchQuery{
+				Words: []string{"go"},
+				Since: time.Date(2021, 3, 1, 0, 0, 0, 0, loc),
+				Until: time.Date(2021, 3, 11, 0, 0, 0, 0, loc),
+			},
+		},
+		{query: "Desde:2021-03-01", want: searchQuery{Since: time.Date(2021, 3, 1, 0, 0, 0, 0, loc)}},
+		{query: "http://example.com", want: searchQuery{Words: []string{"http", "example", "com"}}},
+		{query: "since:yesterday", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSearchQuery(tt.query, loc)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSearchQuery(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if !reflect.DeepEqual(got.Words, tt.want.Words) || !got.Since.Equal(tt.want.Since) || !got.Until.Equal(tt.want.Until) {
+			t.Errorf("parseSearchQuery(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package bot
+
+import (
+	"sync"
+	"time"
+
+	"github.com/indes/flowerss-bot/bot/fsm"
+	"go.uber.org/zap"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	chatStateKey        = "chat_state"
+	defaultStateTimeout = 5 * time.Minute
+)
+
+// stateTimeout is how long each conversation waits for the reply before it expires. /sub waits the
+// longest, the user may have to look for the url; the other replies pick from a keyboard or follow a button.
+var stateTimeout = map[fsm.UserStatus]time.Duration{
+	fsm.Sub:       10 * time.Minute,
+	fsm.UnSub:     5 * time.Minute,
+	fsm.Set:       5 * time.Minute,
+	fsm.SetSubTag: 2 * time.Minute,
+}
+
+// chatState is the conversation a chat is in, Payload carries data the reply handler needs
+type chatState struct {
+	Status   fsm.UserStatus `json:"status"`
+	Payload  string         `json:"payload,omitempty"`
+	ExpireAt time.Time      `json:"expire_at"`
+}
+
+type stateStore struct {
+	once   sync.Once
+	mu     sync.Mutex
+	states map[int64]*chatState
+	// persister keeps the conversations across restarts, they are only in memory when it is nil
+	persister Persister
+}
+
+var chatStates = &stateStore{}
+
+// SetStatePersister makes the pending conversations survive restarts through p, such as a persister
+// on the database of model. Must be called before the bot starts.
+func SetStatePersister(p Persister) {
+	chatStates.persister = p
+}
+
+func (s *stateStore) load() {
+	s.once.Do(func() {
+		s.states = make(map[int64]*chatState)
+		if s.persister == nil {
+			return
+		}
+		if err := s.persister.Load(chatStateKey, &s.states); err != nil {
+			zap.S().Errorf("load chat state failed, err:%+v", err)
+		}
+	})
+}
+
+// save must be called with s.mu held
+func (s *stateStore) save() {
+	if s.persister == nil {
+		return
+	}
+	if err := s.persister.Save(chatStateKey, s.states); err != nil {
+		zap.S().Errorf("save chat state failed, err:%+v", err)
+	}
+}
+
+// Set puts chatID into status until the status timeout passes
+func (s *stateStore) Set(chatID int64, status fsm.UserStatus, payload string) {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	timeout, ok := stateTimeout[status]
+	if !ok {
+		timeout = defaultStateTimeout
+	}
+
+	now := time.Now()
+	for id, state := range s.states {
+		if now.After(state.ExpireAt) {
+			delete(s.states, id)
+		}
+	}
+	s.states[chatID] = &chatState{Status: status, Payload: payload, ExpireAt: now.Add(timeout)}
+	s.save()
+}
+
+// Get returns the pending conversation of chatID, an expired conversation is returned as fsm.None
+func (s *stateStore) Get(chatID int64) chatState {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[chatID]
+	if !ok {
+		return chatState{Status: fsm.None}
+	}
+	if time.Now().After(state.ExpireAt) {
+		delete(s.states, chatID)
+		s.save()
+		return chatState{Status: fsm.None}
+	}
+	return *state
+}
+
+// Clear ends the conversation of chatID and reports whether one was pending
+func (s *stateStore) Clear(chatID int64) bool {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[chatID]
+	if !ok {
+		return false
+	}
+	delete(s.states, chatID)
+	s.save()
+	return time.Now().Before(state.ExpireAt)
+}
+
+func cancelCmdCtr(m *tb.Message) {
+	lang := msgLang(m)
+	if !chatStates.Clear(m.Chat.ID) {
+		_, _ = B.Send(m.Chat, tr(lang, "cancel_nothing"), &tb.ReplyMarkup{ReplyKeyboardRemove: true})
+		return
+	}
+	_, _ = B.Send(m.Chat, tr(lang, "operation_cancelled"), &tb.ReplyMarkup{ReplyKeyboardRemove: true})
+}
@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/indes/flowerss-bot/bot/fsm"
+)
+
+func TestStateStore(t *testing.T) {
+	s := &stateStore{}
+	if got := s.Get(1).Status; got != fsm.None {
+		t.Errorf("Get() of a new chat = %v, want fsm.None", got)
+	}
+
+	s.Set(1, fsm.Sub, "")
+	s.Set(2, fsm.SetSubTag, "3:2:100")
+	if got := s.Get(1); got.Status != fsm.Sub {
+		t.Errorf("Get(1) = %+v, want fsm.Sub", got)
+	}
+	if got := s.Get(2); got.Status != fsm.SetSubTag || got.Payload != "3:2:100" {
+		t.Errorf("Get(2) = %+v, want the tag reply of chat 2", got)
+	}
+
+	if !s.Clear(1) {
+		t.Error("Clear(1) = false, want true")
+	}
+	if got := s.Get(1).Status; got != fsm.None {
+		t.Errorf("Get(1) after Clear = %v, want fsm.None", got)
+	}
+	if got := s.Get(2).Status; got != fsm.SetSubTag {
+		t.Errorf("Clear(1) ended the conversation of chat 2, got %v", got)
+	}
+	if s.Clear(1) {
+		t.Error("Clear(1) of a cleared chat = true")
+	}
+}
+
+func TestStateStoreExpiry(t *testing.T) {
+	s := &stateStore{}
+	s.Set(1, fsm.SetSubTag, "3:1:100")
+	if ttl := time.Until(s.states[1].ExpireAt); ttl > stateTimeout[fsm.SetSubTag] || ttl < stateTimeout[fsm.SetSubTag]-time.Minute {
+		t.Errorf("tag reply expires in %v, want %v", ttl, stateTimeout[fsm.SetSubTag])
+	}
+
+	s.states[1].ExpireAt = time.Now().Add(-time.Second)
+	if got := s.Get(1).Status; got != fsm.None {
+		t.Errorf("Get() of an expired conversation = %v, want fsm.None", got)
+	}
+
+	s.Set(2, fsm.Sub, "")
+	s.states[2].ExpireAt = time.Now().Add(-time.Second)
+	if s.Clear(2) {
+		t.Error("Clear() of an expired conversation = true, /cancel would report it as cancelled")
+	}
+
+	// expired conversations of other chats are dropped by Set
+	s.Set(3, fsm.Sub, "")
+	s.states[3].ExpireAt = time.Now().Add(-time.Second)
+	s.Set(4, fsm.Sub, "")
+	if _, ok := s.states[3]; ok {
+		t.Error("Set() kept the expired conversation of another chat")
+	}
+}
@@ -0,0 +1,195 @@
+package bot
+
+import (
+	"sync"
+
+	"github.com/indes/flowerss-bot/model"
+	"go.uber.org/zap"
+)
+
+const subOptionKey = "sub_options"
+
+// SubOption holds the per subscription settings that model.Subscribe has no column for, keyed by Subscribe.ID
+type SubOption struct {
+	Paused   bool         `json:"paused,omitempty"`
+	Filters  []FilterRule `json:"filters,omitempty"`
+	Filtered uint64       `json:"filtered,omitempty"`
+	Delivery deliveryMode `json:"delivery,omitempty"`
+}
+
+type subOptionStore struct {
+	once sync.Once
+	mu   sync.RWMutex
+	opts map[uint]*SubOption
+	// dirty is set by UpdateLater, flush saves the options
+	dirty bool
+}
+
+var subOptions = &subOptionStore{}
+
+func (s *subOptionStore) load() {
+	s.once.Do(func() {
+		s.opts = make(map[uint]*SubOption)
+		if err := persister.Load(subOptionKey, &s.opts); err != nil {
+			zap.S().Errorf("load subscription options failed, err:%+v", err)
+		}
+	})
+}
+
+// Get returns a copy of the options of subID
+func (s *subOptionStore) Get(subID uint) SubOption {
+	s.load()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if opt, ok := s.opts[subID]; ok {
+		return *opt
+	}
+	return SubOption{}
+}
+
+// Update applies fn to the options of every subID and saves them once
+func (s *subOptionStore) Update(fn func(opt *SubOption), subIDs ...uint) error {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apply(fn, subIDs)
+	return s.save()
+}
+
+// UpdateLater applies fn to the options of every subID, they are saved by the next flush.
+// It is meant for the counters updated on every pushed item.
+func (s *subOptionStore) UpdateLater(fn func(opt *SubOption), subIDs ...uint) {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apply(fn, subIDs)
+	s.dirty = true
+}
+
+// apply must be called with s.mu held
+func (s *subOptionStore) apply(fn func(opt *SubOption), subIDs []uint) {
+	for _, id := range subIDs {
+		opt, ok := s.opts[id]
+		if !ok {
+			opt = &SubOption{}
+			s.opts[id] = opt
+		}
+		fn(opt)
+	}
+}
+
+func (s *subOptionStore) flush() {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return
+	}
+	if err := s.save(); err != nil {
+		zap.S().Errorf("save subscription options failed, err:%+v", err)
+	}
+}
+
+// save must be called with s.mu held
+func (s *subOptionStore) save() error {
+	if err := persister.Save(subOptionKey, s.opts); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// setSubsPausedByUserID pauses or resumes every subscription of userID, the shared sources are untouched
+func setSubsPausedByUserID(userID int64, paused bool) error {
+	subs, err := model.GetSubsByUserID(userID)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]uint, 0, len(subs))
+	for _, sub := range subs {
+		ids = append(ids, sub.ID)
+	}
+	return subOptions.Update(func(opt *SubOption) { opt.Paused = paused }, ids...)
+}
+
+// Delete drops the options of subIDs, the subscriptions were removed
+func (s *subOptionStore) Delete(subIDs ...uint) error {
+	s.load()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range subIDs {
+		delete(s.opts, id)
+	}
+	return s.save()
+}
+
+// forgetSubOptions drops the options of removed subscriptions
+func forgetSubOptions(subIDs ...uint) {
+	if len(subIDs) == 0 {
+		return
+	}
+	if err := subOptions.Delete(subIDs...); err != nil {
+		zap.S().Errorf("delete options of subscriptions %v failed, err:%+v", subIDs, err)
+	}
+}
+
+// unsubSource unsubscribes userID from source and drops the options of the subscription
+func unsubSource(userID int64, source *model.Source) error {
+	sub, _ := model.GetSubscribeByUserIDAndSourceID(userID, source.ID)
+	if err := model.UnsubByUserIDAndSource(userID, source); err != nil {
+		return err
+	}
+	if sub != nil {
+		forgetSubOptions(sub.ID)
+	}
+	return nil
+}
+
+// unsubSubID removes the subscription subID of userID and its options
+func unsubSubID(userID int64, subID uint) error {
+	if err := model.UnsubByUserIDAndSubID(userID, subID); err != nil {
+		return err
+	}
+	forgetSubOptions(subID)
+	return nil
+}
+
+// unsubSub removes sub and its options
+func unsubSub(sub *model.Subscribe) error {
+	if err := sub.Unsub(); err != nil {
+		return err
+	}
+	forgetSubOptions(sub.ID)
+	return nil
+}
+
+// unsubAll removes every subscription of userID and their options
+func unsubAll(userID int64) (int, int, error) {
+	subs, err := model.GetSubsByUserID(userID)
+	if err != nil {
+		return 0, 0, err
+	}
+	success, fail, err := model.UnsubAllByUserID(userID)
+	if err != nil {
+		return success, fail, err
+	}
+
+	var ids []uint
+	for _, sub := range subs {
+		// the subscriptions that failed keep their options
+		if fail > 0 {
+			if remaining, _ := model.GetSubscribeByID(int(sub.ID)); remaining != nil {
+				continue
+			}
+		}
+		ids = append(ids, sub.ID)
+	}
+	forgetSubOptions(ids...)
+	return success, fail, nil
+}
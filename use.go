@@ -0,0 +1,121 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"go.uber.org/zap"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+// chatName returns @username of chat, or its title when it has none
+func chatName(chat *tb.Chat) string {
+	if chat.Username != "" {
+		return "@" + chat.Username
+	}
+	return chat.Title
+}
+
+// chatRef returns the reference B.ChatByID resolves to chat
+func chatRef(chat *tb.Chat) string {
+	if chat.Username != "" {
+		return "@" + chat.Username
+	}
+	return fmt.Sprintf("%d", chat.ID)
+}
+
+// commandArgs returns the arguments of the command without @mentions,
+// so the position of the arguments doesn't depend on the target
+func commandArgs(m *tb.Message) []string {
+	var args []string
+	for _, arg := range strings.Fields(m.Payload) {
+		if !strings.HasPrefix(arg, "@") {
+			args = append(args, arg)
+		}
+	}
+	return args
+}
+
+// commandRest returns the text of the command after its n first arguments and the @mentions around them,
+// for the arguments that may contain spaces
+func commandRest(m *tb.Message, n int) string {
+	rest := strings.TrimSpace(m.Payload)
+	for rest != "" && (n > 0 || strings.HasPrefix(rest, "@")) {
+		if !strings.HasPrefix(rest, "@") {
+			n--
+		}
+		end := strings.IndexFunc(rest, unicode.IsSpace)
+		if end < 0 {
+			end = len(rest)
+		}
+		rest = strings.TrimSpace(rest[end:])
+	}
+	return rest
+}
+
+// sessionTarget returns the chat set by /use, the session is dropped when the sender no longer manages it
+func sessionTarget(m *tb.Message, lang string, target int64) *tb.Chat {
+	chat, err := B.ChatByID(fmt.Sprintf("%d", target))
+	if err != nil {
+		_, _ = B.Send(m.Chat, tr(lang, "channel_error"))
+		return nil
+	}
+	if !canManageChat(m.Sender, chat) {
+		if err := chatOptions.Update(m.Chat.ID, func(opt *ChatOption) { opt.Target = 0 }); err != nil {
+			zap.S().Errorf("reset target of chat %d failed, err:%+v", m.Chat.ID, err)
+		}
+		_, _ = B.Send(m.Chat, tr(lang, "use_target_lost", chatName(chat)))
+		return nil
+	}
+	return chat
+}
+
+func useCmdCtr(m *tb.Message) {
+	lang := msgLang(m)
+	if m.Chat.Type != tb.ChatPrivate {
+		_, _ = B.Send(m.Chat, tr(lang, "use_private_only"))
+		return
+	}
+
+	arg := strings.TrimSpace(m.Payload)
+	switch {
+	case arg == "":
+		target := chatOptions.Get(m.Chat.ID).Target
+		if target == 0 {
+			_, _ = B.Send(m.Chat, tr(lang, "use_current_me"))
+			return
+		}
+		chat := sessionTarget(m, lang, target)
+		if chat == nil {
+			return
+		}
+		_, _ = B.Send(m.Chat, tr(lang, "use_current", chatName(chat)))
+		return
+	case arg == "me":
+		err := chatOptions.Update(m.Chat.ID, func(opt *ChatOption) { opt.Target = 0 })
+		if err != nil {
+			zap.S().Errorf("reset target of chat %d failed, err:%+v", m.Chat.ID, err)
+			_, _ = B.Send(m.Chat, tr(lang, "error"))
+			return
+		}
+		_, _ = B.Send(m.Chat, tr(lang, "use_reset"))
+		return
+	case !strings.HasPrefix(arg, "@"):
+		_, _ = B.Send(m.Chat, tr(lang, "use_usage"))
+		return
+	}
+
+	chat := authTarget(m, lang, arg)
+	if chat == nil {
+		return
+	}
+	err := chatOptions.Update(m.Chat.ID, func(opt *ChatOption) { opt.Target = chat.ID })
+	if err != nil {
+		zap.S().Errorf("set target of chat %d failed, err:%+v", m.Chat.ID, err)
+		_, _ = B.Send(m.Chat, tr(lang, "error"))
+		return
+	}
+	_, _ = B.Send(m.Chat, tr(lang, "use_set", chatName(chat)))
+}
@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+func TestCommandArgs(t *testing.T) {
+	tests := []struct {
+		payload string
+		want    []string
+	}{
+		{"@news12 12 +go", []string{"12", "+go"}},
+		{"12 @news +title:/go 1\\.\\d+/", []string{"12", "+title:/go", "1\\.\\d+/"}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		if got := commandArgs(&tb.Message{Payload: tt.payload}); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("commandArgs(%q) = %q, want %q", tt.payload, got, tt.want)
+		}
+	}
+}
+
+func TestCommandRest(t *testing.T) {
+	tests := []struct {
+		payload string
+		n       int
+		want    string
+	}{
+		{"@news12 12 +go", 1, "+go"},
+		{"12 @news12 -/ads  and  promos/", 1, "-/ads  and  promos/"},
+		{"12 +@golang news", 1, "+@golang news"},
+		{"tag #go daily", 2, "daily"},
+		{"12", 1, ""},
+		{"", 1, ""},
+	}
+
+	for _, tt := range tests {
+		if got := commandRest(&tb.Message{Payload: tt.payload}, tt.n); got != tt.want {
+			t.Errorf("commandRest(%q, %d) = %q, want %q", tt.payload, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/indes/flowerss-bot/model"
+	"go.uber.org/zap"
+
+	tb "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	maxWatches = 20
+	// watchSnippet is the number of bytes of content shown around a match
+	watchSnippet = 80
+)
+
+// Watch alerts a chat of the items of any of its subscriptions matching Pattern
+type Watch struct {
+	Regex   bool   `json:"regex,omitempty"`
+	Pattern string `json:"pattern"`
+	Hits    uint64 `json:"hits,omitempty"`
+}
+
+// parseWatch parses "keyword" or "/regex/"
+func parseWatch(spec string) (Watch, error) {
+	spec = strings.TrimSpace(spec)
+	if len(spec) > 2 && strings.HasPrefix(spec, "/") && strings.HasSuffix(spec, "/") {
+		w := Watch{Regex: true, Pattern: spec[1 : len(spec)-1]}
+		re, err := compileFilter(w.Pattern)
+		if err != nil {
+			return w, err
+		}
+		if re.MatchString("") {
+			return w, fmt.Errorf("%s matches every item", w)
+		}
+		return w, nil
+	}
+	if spec == "" {
+		return Watch{}, fmt.Errorf("empty watch")
+	}
+	return Watch{Pattern: spec}, nil
+}
+
+func (w Watch) String() string {
+	if w.Regex {
+		return "/" + w.Pattern + "/"
+	}
+	return w.Pattern
+}
+
+// regexp returns the expression of w, keywords match regardless of case
+func (w Watch) regexp() (*regexp.Regexp, error) {
+	if w.Regex {
+		return compileFilter(w.Pattern)
+	}
+	return compileFilter("(?i)" + regexp.QuoteMeta(w.Pattern))
+}
+
+// hashtag is the tag of the alerts of w, keywords become #watch_keyword
+func (w Watch) hashtag() string {
+	if w.Regex {
+		return "#watch"
+	}
+	tag := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return r
+		}
+		return '_'
+	}, w.Pattern)
+	return "#watch_" + tag
+}
+
+// watchMatch is an item matching watches, with the ranges matched in its title and text
+type watchMatch struct {
+	watches []Watch
+	title   [][]int
+	text    [][]int
+}
+
+// matchWatches returns the watches of the chat that title or text match, nil when none does
+func matchWatches(watches []Watch, title string, text string) *watchMatch {
+	var match *watchMatch
+	for _, w := range watches {
+		re, err := w.regexp()
+		if err != nil {
+			continue
+		}
+		inTitle := re.FindAllStringIndex(title, -1)
+		inText := re.FindAllStringIndex(text, -1)
+		if inTitle == nil && inText == nil {
+			continue
+		}
+		if match == nil {
+			match = &watchMatch{}
+		}
+		match.watches = append(match.watches, w)
+		match.title = append(match.title, inTitle...)
+		match.text = append(match.text, inText...)
+	}
+	return match
+}
+
+// highlight escapes text and underlines the ranges in bold, ranges may overlap
+func highlight(text string, ranges [][]int) string {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	var b strings.Builder
+	pos := 0
+	for _, r := range ranges {
+		start, end := r[0], r[1]
+		if start < pos {
+			start = pos
+		}
+		if end <= start {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:start]))
+		b.WriteString("<b><u>" + html.EscapeString(text[start:end]) + "</u></b>")
+		pos = end
+	}
+	b.WriteString(html.EscapeString(text[pos:]))
+	return b.String()
+}
+
+// snippet returns the part of text around its first range, highlighted
+func snippet(text string, ranges [][]int) string {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	start, end := ranges[0][0]-watchSnippet, ranges[0][1]+watchSnippet
+	if start < 0 {
+		start = 0
+	}
+	if end > len(text) {
+		end = len(text)
+	}
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end++
+	}
+
+	var moved [][]int
+	for _, r := range ranges {
+		if r[0] >= start && r[1] <= end {
+			moved = append(moved, []int{r[0] - start, r[1] - start})
+		}
+	}
+	prefix, suffix := "", ""
+	if start > 0 {
+		prefix = "…"
+	}
+	if end < len(text) {
+		suffix = "…"
+	}
+	return prefix + highlight(text[start:end], moved) + suffix
+}
+
+// renderWatchAlert returns the alert of content matching m
+func renderWatchAlert(lang string, source *model.Source, content *model.Content, text string, m *watchMatch) string {
+	var tags []string
+	for _, w := range m.watches {
+		tags = append(tags, w.hashtag())
+	}
+	msg := tr(lang, "watch_alert", strings.Join(tags, " "))
+	msg += fmt.Sprintf("\n<b>%s</b>\n<a href=\"%s\">%s</a>", html.EscapeString(source.Title), html.EscapeString(content.RawLink), highlight(content.Title, m.title))
+	if len(m.text) > 0 {
+		msg += "\n\n" + snippet(text, m.text)
+	}
+	return msg
+}
+
+// alertWatches sends content to the chat of sub when it matches a watch of the chat, even when the
+// notifications of sub are off. It reports whether an alert was sent, BroadcastNews then skips the push.
+func alertWatches(source *model.Source, sub *model.Subscribe, content *model.Content) bool {
+	opt := chatOptions.Get(sub.UserID)
+	if len(opt.Watches) == 0 {
+		return false
+	}
+	text := strings.Join(strings.Fields(html.UnescapeString(htmlTagRegexp.ReplaceAllString(content.Description, " "))), " ")
+	match := matchWatches(opt.Watches, content.Title, text)
+	if match == nil {
+		return false
+	}
+
+	lang := chatLang(&tb.Chat{ID: sub.UserID}, nil)
+	_, err := B.Send(&tb.Chat{ID: sub.UserID}, renderWatchAlert(lang, source, content, text, match), &tb.SendOptions{
+		DisableWebPagePreview: true,
+		ParseMode:             tb.ModeHTML,
+	})
+	if err != nil {
+		zap.S().Warnf("send watch alert to %d failed, err:%+v", sub.UserID, err)
+		return false
+	}
+
+	chatOptions.UpdateLater(sub.UserID, func(opt *ChatOption) {
+		// copy before counting, readers may still hold the old slice
+		watches := append([]Watch(nil), opt.Watches...)
+		for i := range watches {
+			for _, w := range match.watches {
+				if watches[i].Pattern == w.Pattern && watches[i].Regex == w.Regex {
+					watches[i].Hits++
+				}
+			}
+		}
+		opt.Watches = watches
+	})
+	return true
+}
+
+func watchCmdCtr(m *tb.Message) {
+	lang := msgLang(m)
+	target := authTarget(m, lang, GetMentionFromMessage(m))
+	if target == nil {
+		return
+	}
+
+	spec := strings.Join(commandArgs(m), " ")
+	if spec == "" {
+		_, _ = B.Send(m.Chat, tr(lang, "watch_usage"))
+		return
+	}
+	w, err := parseWatch(spec)
+	if err != nil {
+		_, _ = B.Send(m.Chat, tr(lang, "watch_invalid", err.Error()))
+		return
+	}
+
+	opt := chatOptions.Get(target.ID)
+	if len(opt.Watches) >= maxWatches {
+		_, _ = B.Send(m.Chat, tr(lang, "watch_too_many", maxWatches))
+		return
+	}
+	for _, existing := range opt.Watches {
+		if existing.String() == w.String() {
+			sendWatches(m.Chat, lang, target.ID)
+			return
+		}
+	}
+
+	err = chatOptions.Update(target.ID, func(opt *ChatOption) {
+		opt.Watches = append(append([]Watch(nil), opt.Watches...), w)
+	})
+	if err != nil {
+		zap.S().Errorf("save watches of %d failed, err:%+v", target.ID, err)
+		_, _ = B.Send(m.Chat, tr(lang, "error"))
+		return
+	}
+	sendWatches(m.Chat, lang, target.ID)
+}
+
+func watchesCmdCtr(m *tb.Message) {
+	lang := msgLang(m)
+	target := authTarget(m, lang, GetMentionFromMessage(m))
+	if target == nil {
+		return
+	}
+	sendWatches(m.Chat, lang, target.ID)
+}
+
+func watchListMessage(lang string, watches []Watch) string {
+	if len(watches) == 0 {
+		return tr(lang, "watch_list_empty")
+	}
+	msg := tr(lang, "watch_list_title")
+	for i, w := range watches {
+		msg += fmt.Sprintf("\n[%d] <code>%s</code> %s (%d)", i+1, html.EscapeString(w.String()), w.hashtag(), w.Hits)
+	}
+	return msg
+}
+
+func genWatchBtn(lang string, chatID int64, owner int64, watches []Watch) [][]tb.InlineButton {
+	var keys [][]tb.InlineButton
+	for i, w := range watches {
+		keys = append(keys, []tb.InlineButton{
+			tb.InlineButton{
+				Unique: "del_watch_btn",
+				Text:   tr(lang, "btn_watch_delete", w.String()),
+				Data:   newCallback(chatID, callbackPayload{Owner: owner, Kind: "watch", Index: i, Arg: w.String()}),
+			},
+		})
+	}
+	return keys
+}
+
+func sendWatches(chat *tb.Chat, lang string, owner int64) {
+	watches := chatOptions.Get(owner).Watches
+	_, _ = B.Send(chat, watchListMessage(lang, watches), &tb.SendOptions{ParseMode: tb.ModeHTML}, &tb.ReplyMarkup{
+		InlineKeyboard: genWatchBtn(lang, chat.ID, owner, watches),
+	})
+}
+
+func delWatchBtnCtr(c *tb.Callback) {
+	payload, ok := loadCallback(c)
+	if !ok || payload.Kind != "watch" || !callbackAuth(c, payload.Owner) {
+		return
+	}
+	lang := cbLang(c)
+
+	index := payload.Index
+	err := chatOptions.Update(payload.Owner, func(opt *ChatOption) {
+		// the list may have changed since the buttons were sent
+		if index < 0 || index >= len(opt.Watches) || opt.Watches[index].String() != payload.Arg {
+			return
+		}
+		watches := make([]Watch, 0, len(opt.Watches)-1)
+		watches = append(watches, opt.Watches[:index]...)
+		opt.Watches = append(watches, opt.Watches[index+1:]...)
+	})
+	if err != nil {
+		_ = B.Respond(c, &tb.CallbackResponse{Text: tr(lang, "error")})
+		return
+	}
+	_ = B.Respond(c)
+
+	watches := chatOptions.Get(payload.Owner).Watches
+	_, _ = B.Edit(c.Message, watchListMessage(lang, watches), &tb.SendOptions{ParseMode: tb.ModeHTML}, &tb.ReplyMarkup{
+		InlineKeyboard: genWatchBtn(lang, c.Message.Chat.ID, payload.Owner, watches),
+	})
+}
@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"testing"
+)
+
+func TestParseWatch(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Watch
+		wantErr bool
+	}{
+		{spec: " golang ", want: Watch{Pattern: "golang"}},
+		{spec: `/go\s?1\.\d+/`, want: Watch{Regex: true, Pattern: `go\s?1\.\d+`}},
+		{spec: "//", want: Watch{Pattern: "//"}},
+		{spec: "", wantErr: true},
+		{spec: "/(unclosed/", wantErr: true},
+		{spec: "/.*/", wantErr: true},
+		{spec: "/a?/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseWatch(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseWatch(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseWatch(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		text   string
+		ranges [][]int
+		want   string
+	}{
+		{"Go & Rust", nil, "Go &amp; Rust"},
+		{"Go & Rust", [][]int{{5, 9}, {0, 2}}, "<b><u>Go</u></b> &amp; <b><u>Rust</u></b>"},
+		{"golang", [][]int{{0, 4}, {2, 6}}, "<b><u>gola</u></b><b><u>ng</u></b>"},
+		{"<go>", [][]int{{1, 3}}, "&lt;<b><u>go</u></b>&gt;"},
+	}
+
+	for _, tt := range tests {
+		if got := highlight(tt.text, tt.ranges); got != tt.want {
+			t.Errorf("highlight(%q, %v) = %q, want %q", tt.text, tt.ranges, got, tt.want)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	short := "release of go 1.16"
+	if got, want := snippet(short, [][]int{{11, 13}}), "release of <b><u>go</u></b> 1.16"; got != want {
+		t.Errorf("snippet() = %q, want %q", got, want)
+	}
+
+	padding := ""
+	for i := 0; i < watchSnippet; i++ {
+		padding += "é"
+	}
+	long := padding + "go" + padding
+	got := snippet(long, [][]int{{len(padding), len(padding) + 2}})
+	want := "…" + padding[len(padding)-watchSnippet:] + "<b><u>go</u></b>" + padding[:watchSnippet] + "…"
+	if got != want {
+		t.Errorf("snippet() = %q, want %q", got, want)
+	}
+}
+
+func TestMatchWatches(t *testing.T) {
+	watches := []Watch{{Pattern: "Go"}, {Regex: true, Pattern: `rust \d`}}
+	if m := matchWatches(watches, "Python news", "nothing here"); m != nil {
+		t.Errorf("matchWatches() = %+v, want nil", m)
+	}
+
+	m := matchWatches(watches, "GO 1.16", "rust 2 and go")
+	if m == nil {
+		t.Fatal("matchWatches() = nil")
+	}
+	if len(m.watches) != 2 || len(m.title) != 1 || len(m.text) != 2 {
+		t.Errorf("matchWatches() = %+v", m)
+	}
+}